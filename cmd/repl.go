@@ -0,0 +1,212 @@
+/*
+Copyright © 2022 Dane Walker <dane@danecwalker.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/danecwalker/ponic"
+	"github.com/danecwalker/ponic/engine/lexer"
+	"github.com/danecwalker/ponic/engine/object"
+	"github.com/danecwalker/ponic/engine/parser"
+	"github.com/spf13/cobra"
+)
+
+const (
+	prompt         = ">> "
+	continuePrompt = ".. "
+)
+
+const replHelp = `:ast <code>     print the syntax tree of code
+:tokens <code>  print the tokens of code
+:history        list the inputs entered so far
+:reset          discard every binding
+:help           show this help
+:quit           leave the REPL
+`
+
+// replCmd represents the repl command
+var replCmd = &cobra.Command{
+	Use:   "repl",
+	Short: "Start an interactive Ponic session",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		r := &repl{in: cmd.InOrStdin(), out: cmd.OutOrStdout(), errOut: cmd.ErrOrStderr()}
+		r.run()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replCmd)
+}
+
+type repl struct {
+	in     io.Reader
+	out    io.Writer
+	errOut io.Writer
+
+	// reader buffers in for both the REPL and the scan builtin, so that
+	// neither reads ahead into input meant for the other
+	reader *bufio.Reader
+
+	interp  *ponic.Interpreter
+	history []string
+}
+
+func (r *repl) reset() {
+	r.interp = ponic.New()
+	r.interp.SetStdin(r.reader)
+	r.interp.SetStdout(r.out)
+	r.interp.SetStderr(r.errOut)
+}
+
+func (r *repl) run() {
+	r.reader = bufio.NewReader(r.in)
+	r.reset()
+
+	fmt.Fprint(r.out, "Ponic REPL, :help for commands\n")
+	for {
+		input, ok := r.read()
+		if !ok {
+			fmt.Fprintln(r.out)
+			return
+		}
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(input), ":") {
+			if !r.command(strings.TrimSpace(input)) {
+				return
+			}
+			continue
+		}
+
+		r.history = append(r.history, input)
+		r.eval(input)
+	}
+}
+
+// read reads one input, continuing onto further lines while its brackets
+// are unbalanced. It reports false at the end of the input.
+func (r *repl) read() (string, bool) {
+	fmt.Fprint(r.out, prompt)
+	input, ok := r.readLine()
+	if !ok {
+		return "", false
+	}
+
+	for !strings.HasPrefix(strings.TrimSpace(input), ":") && depth(input) > 0 {
+		fmt.Fprint(r.out, continuePrompt)
+		line, ok := r.readLine()
+		if !ok {
+			return input, true
+		}
+		input += "\n" + line
+	}
+	return input, true
+}
+
+// readLine reads one line without its line ending. It reports false at the
+// end of the input.
+func (r *repl) readLine() (string, bool) {
+	line, err := r.reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.TrimRight(line, "\r\n"), true
+}
+
+// depth returns how many brackets of input are open at its end. Input with
+// an illegal token, such as a string that is never closed, counts as
+// complete so that it is evaluated and the error reported.
+func depth(input string) int {
+	l := lexer.NewLexer(bufio.NewReader(strings.NewReader(input)))
+
+	depth := 0
+	for tok := l.Next(); tok.Type != lexer.EOF; tok = l.Next() {
+		switch tok.Type {
+		case lexer.ILLEGAL:
+			return 0
+		case lexer.LBRACE, lexer.LPAREN, lexer.LBRACKET:
+			depth++
+		case lexer.RBRACE, lexer.RPAREN, lexer.RBRACKET:
+			depth--
+		}
+	}
+	return depth
+}
+
+func (r *repl) eval(input string) {
+	result, err := r.interp.Eval(input)
+	if err != nil {
+		fmt.Fprintln(r.errOut, err)
+		return
+	}
+
+	if ret, ok := result.(*object.ReturnValue); ok {
+		result = ret.Value
+	}
+	if _, ok := result.(*object.Null); !ok {
+		fmt.Fprintln(r.out, result.Inspect())
+	}
+}
+
+// command runs a meta-command and reports whether the REPL should carry on.
+func (r *repl) command(input string) bool {
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":quit", ":q":
+		return false
+	case ":help":
+		fmt.Fprint(r.out, replHelp)
+	case ":reset":
+		r.reset()
+	case ":history":
+		for n, input := range r.history {
+			fmt.Fprintf(r.out, "%4d  %s\n", n+1, strings.ReplaceAll(input, "\n", "\n      "))
+		}
+	case ":tokens":
+		l := lexer.NewLexer(bufio.NewReader(strings.NewReader(arg)), lexer.WithComments())
+		for tok := l.Next(); tok.Type != lexer.EOF; tok = l.Next() {
+			fmt.Fprintf(r.out, "%d:%d\t%s\t%q\n", tok.Pos.Line, tok.Pos.Column, tok.Type, tok.Literal)
+		}
+	case ":ast":
+		program, diagnostics := parser.NewParser(lexer.NewLexer(bufio.NewReader(strings.NewReader(arg)))).Parse()
+		for _, d := range diagnostics {
+			fmt.Fprintln(r.errOut, d)
+		}
+		if len(diagnostics) == 0 {
+			for _, stmt := range program.Statements {
+				fmt.Fprintln(r.out, stmt)
+			}
+		}
+	default:
+		fmt.Fprintf(r.errOut, "unknown command %s, :help for commands\n", name)
+	}
+	return true
+}
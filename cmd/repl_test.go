@@ -0,0 +1,75 @@
+/*
+Copyright © 2022 Dane Walker <dane@danecwalker.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func runREPL(input string) (string, string) {
+	var out, errOut strings.Builder
+	r := &repl{in: strings.NewReader(input), out: &out, errOut: &errOut}
+	r.run()
+	return out.String(), errOut.String()
+}
+
+func TestREPLScan(t *testing.T) {
+	out, errOut := runREPL(`let name = scan("name? ");
+world
+let n = int(scan());
+41
+"hello " + name
+n + 1
+`)
+	if errOut != "" {
+		t.Fatalf("unexpected errors:\n%s", errOut)
+	}
+	for _, want := range []string{"name? ", "hello world\n", "42\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q does not contain %q", out, want)
+		}
+	}
+}
+
+func TestREPLMultiLine(t *testing.T) {
+	out, errOut := runREPL("fn add(a, b) {\n  return a + b;\n}\nadd(2, 3)\n:history\n")
+	if errOut != "" {
+		t.Fatalf("unexpected errors:\n%s", errOut)
+	}
+	if !strings.Contains(out, ".. .. >> 5\n") {
+		t.Errorf("output %q does not show the continuation prompts and result", out)
+	}
+	if !strings.Contains(out, "   2  add(2, 3)\n") {
+		t.Errorf("output %q does not list the history", out)
+	}
+}
+
+func TestREPLUnterminatedString(t *testing.T) {
+	out, errOut := runREPL("print(\"abc)\n1 + 1\n")
+	if !strings.Contains(errOut, "unterminated string") {
+		t.Errorf("errors %q do not report the unterminated string", errOut)
+	}
+	if !strings.Contains(out, "2\n") {
+		t.Errorf("output %q does not carry on after the error", out)
+	}
+}
@@ -22,14 +22,12 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"bufio"
+	"fmt"
 	"os"
 	"path"
 
-	"github.com/danecwalker/ponic/engine/lexer"
+	"github.com/danecwalker/ponic"
 	"github.com/danecwalker/ponic/engine/object"
-	"github.com/danecwalker/ponic/engine/parser"
-	"github.com/danecwalker/ponic/engine/runtime"
 	"github.com/spf13/cobra"
 )
 
@@ -43,20 +41,33 @@ var rootCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		file := args[0]
 		if ext := path.Ext(file); ext != ".pc" {
-			panic("File must be a .pc file")
+			fmt.Fprintln(os.Stderr, "File must be a .pc file")
+			os.Exit(1)
 		}
 
-		f, err := os.Open(args[0])
-		if err != nil {
-			panic(err)
+		interp := ponic.New()
+		switch engine, _ := cmd.Flags().GetString("engine"); engine {
+		case "walker":
+		case "vm":
+			interp.SetEngine(ponic.VM)
+		default:
+			fmt.Fprintf(os.Stderr, "unknown engine %q: must be walker or vm\n", engine)
+			os.Exit(1)
 		}
 
-		l := lexer.NewLexer(bufio.NewReader(f))
-		p := parser.NewParser(l)
-		// fmt.Println(p.Parse())
+		result, err := interp.RunFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 
-		global_scope := object.NewScope()
-		runtime.Run(p.Parse(), global_scope)
+		// a top-level return ends the script with its value as the exit
+		// status
+		if ret, ok := result.(*object.ReturnValue); ok {
+			if status, ok := ret.Value.(*object.Integer); ok {
+				os.Exit(int(status.Value))
+			}
+		}
 	},
 }
 
@@ -78,5 +89,5 @@ func init() {
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.Flags().String("engine", "walker", "engine to run the program with: walker or vm")
 }
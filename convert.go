@@ -0,0 +1,436 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ponic
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToObject converts a Go value to a Ponic value. Booleans, integers,
+// floats and strings become their Ponic counterparts, slices and arrays
+// become arrays, maps become hashes, structs become struct instances with
+// one field per exported field, and functions become builtins as with
+// RegisterFunc. A nil pointer, interface or func becomes null. Values that
+// already are an object.Object are returned as is.
+//
+// The name of a struct field in Ponic can be set with a `ponic:"name"` tag;
+// fields tagged `ponic:"-"` are left out. A value that contains itself,
+// through a pointer, map or slice, cannot be converted.
+func ToObject(v interface{}) (object.Object, error) {
+	if v == nil {
+		return &object.Null{}, nil
+	}
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	c := &converter{visiting: make(map[visit]bool)}
+	return c.toObject(v)
+}
+
+// visit identifies a pointer, map or slice by its address and type.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// converter converts one Go value. It tracks the pointers, maps and slices
+// it is inside of, to report a cycle rather than recurse forever; a value
+// shared by several parts of the input that does not contain itself is
+// converted each time it is reached.
+type converter struct {
+	visiting map[visit]bool
+}
+
+// enter records that the conversion is inside v, or fails if it already is.
+// The returned function leaves v again.
+func (c *converter) enter(v reflect.Value) (func(), error) {
+	if v.IsNil() || (v.Kind() == reflect.Slice && v.Len() == 0) {
+		return func() {}, nil
+	}
+	key := visit{v.Pointer(), v.Type()}
+	if c.visiting[key] {
+		return nil, fmt.Errorf("cannot convert %s: the value contains itself", v.Type())
+	}
+	c.visiting[key] = true
+	return func() { delete(c.visiting, key) }, nil
+}
+
+func (c *converter) toObject(v reflect.Value) (object.Object, error) {
+	if v.Type().Implements(objectType) {
+		if v.Kind() == reflect.Interface && v.IsNil() {
+			return &object.Null{}, nil
+		}
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return &object.Boolean{Value: v.Bool()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows a Ponic integer", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return &object.Array{Elements: []object.Object{}}, nil
+		}
+		if v.Kind() == reflect.Slice {
+			leave, err := c.enter(v)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			el, err := c.toObject(v.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		leave, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		return c.mapToHash(v)
+	case reflect.Struct:
+		return c.structToInstance(v)
+	case reflect.Interface:
+		if v.IsNil() {
+			return &object.Null{}, nil
+		}
+		return c.toObject(v.Elem())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return &object.Null{}, nil
+		}
+		leave, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		return c.toObject(v.Elem())
+	case reflect.Func:
+		if v.IsNil() {
+			return &object.Null{}, nil
+		}
+		fn, err := wrapFunc("<anonymous>", v)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Builtin{Func: fn}, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a Ponic value", v.Type())
+	}
+}
+
+// mapToHash converts a map to a hash whose keys are in sorted order, so
+// that the result does not depend on Go's map iteration order.
+func (c *converter) mapToHash(v reflect.Value) (object.Object, error) {
+	type entry struct {
+		key   object.Hashable
+		value reflect.Value
+	}
+
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := c.toObject(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		hashable, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("map key type %s is unusable as a hash key", v.Type().Key())
+		}
+		entries = append(entries, entry{hashable, iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].key.HashKey(), entries[j].key.HashKey()
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Int != b.Int {
+			return a.Int < b.Int
+		}
+		return a.Str < b.Str
+	})
+
+	hash := object.NewHash()
+	for _, e := range entries {
+		val, err := c.toObject(e.value)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", e.key.Inspect(), err)
+		}
+		hash.Set(e.key, val)
+	}
+	return hash, nil
+}
+
+func (c *converter) structToInstance(v reflect.Value) (object.Object, error) {
+	fields := structFields(v.Type())
+	st := &object.StructType{Name: v.Type().Name(), Methods: map[string]object.Object{}}
+	inst := &object.Instance{Struct: st, Fields: make(map[string]object.Object, len(fields))}
+
+	for _, f := range fields {
+		val, err := c.toObject(v.FieldByIndex(f.index))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+		st.Fields = append(st.Fields, f.name)
+		inst.Fields[f.name] = val
+	}
+	return inst, nil
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields lists the exported fields of t under their Ponic names.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("ponic"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		fields = append(fields, structField{name, f.Index})
+	}
+	return fields
+}
+
+// FromObject stores the Go equivalent of a Ponic value in the value that
+// target points to. It is the inverse of ToObject: an interface{} target
+// receives an int64, float64, string, bool, nil, []interface{} or
+// map[string]interface{}, and a struct target can be filled from either a
+// struct instance or a hash with string keys.
+func FromObject(obj object.Object, target interface{}) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("FromObject target must be a non-nil pointer, got %T", target)
+	}
+
+	v, err := fromObject(obj, ptr.Type().Elem())
+	if err != nil {
+		return err
+	}
+	ptr.Elem().Set(v)
+	return nil
+}
+
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if t.Implements(objectType) && reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj), nil
+	}
+
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Inspect(), t)
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return mismatch()
+		}
+		if _, ok := obj.(*object.Null); ok {
+			return reflect.Zero(t), nil
+		}
+		v, err := fromObject(obj, naturalType(obj))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return v.Convert(t), nil
+	case reflect.Pointer:
+		if _, ok := obj.(*object.Null); ok {
+			return reflect.Zero(t), nil
+		}
+		elem, err := fromObject(obj, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	}
+
+	v := reflect.New(t).Elem()
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		if t.Kind() != reflect.Bool {
+			return mismatch()
+		}
+		v.SetBool(obj.Value)
+	case *object.Integer:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.OverflowInt(obj.Value) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", obj.Value, t)
+			}
+			v.SetInt(obj.Value)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if obj.Value < 0 || v.OverflowUint(uint64(obj.Value)) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", obj.Value, t)
+			}
+			v.SetUint(uint64(obj.Value))
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(obj.Value))
+		default:
+			return mismatch()
+		}
+	case *object.Float:
+		if t.Kind() != reflect.Float32 && t.Kind() != reflect.Float64 {
+			return mismatch()
+		}
+		v.SetFloat(obj.Value)
+	case *object.String:
+		if t.Kind() != reflect.String {
+			return mismatch()
+		}
+		v.SetString(obj.Value)
+	case *object.Array:
+		return arrayFromObject(obj, t)
+	case *object.Hash:
+		switch t.Kind() {
+		case reflect.Map:
+			return hashToMap(obj, t)
+		case reflect.Struct:
+			return hashToStruct(obj, t)
+		default:
+			return mismatch()
+		}
+	case *object.Instance:
+		switch t.Kind() {
+		case reflect.Struct:
+			return instanceToStruct(obj, t)
+		case reflect.Map:
+			return hashToMap(instanceToHash(obj), t)
+		default:
+			return mismatch()
+		}
+	default:
+		return mismatch()
+	}
+	return v, nil
+}
+
+// naturalType is the Go type a Ponic value converts to when the target is
+// an empty interface.
+func naturalType(obj object.Object) reflect.Type {
+	switch obj.(type) {
+	case *object.Boolean:
+		return reflect.TypeOf(false)
+	case *object.Integer:
+		return reflect.TypeOf(int64(0))
+	case *object.Float:
+		return reflect.TypeOf(float64(0))
+	case *object.String:
+		return reflect.TypeOf("")
+	case *object.Array:
+		return reflect.TypeOf([]interface{}{})
+	case *object.Hash, *object.Instance:
+		return reflect.TypeOf(map[string]interface{}{})
+	default:
+		return objectType
+	}
+}
+
+func arrayFromObject(arr *object.Array, t reflect.Type) (reflect.Value, error) {
+	var v reflect.Value
+	switch t.Kind() {
+	case reflect.Slice:
+		v = reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+	case reflect.Array:
+		if t.Len() != len(arr.Elements) {
+			return reflect.Value{}, fmt.Errorf("cannot use array of length %d as %s", len(arr.Elements), t)
+		}
+		v = reflect.New(t).Elem()
+	default:
+		return reflect.Value{}, fmt.Errorf("cannot use %s as %s", arr.Inspect(), t)
+	}
+
+	for i, el := range arr.Elements {
+		ev, err := fromObject(el, t.Elem())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("index %d: %w", i, err)
+		}
+		v.Index(i).Set(ev)
+	}
+	return v, nil
+}
+
+func hashToMap(hash *object.Hash, t reflect.Type) (reflect.Value, error) {
+	v := reflect.MakeMapWithSize(t, len(hash.Order))
+	for _, pair := range hash.Entries() {
+		key, err := fromObject(pair.Key, t.Key())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		val, err := fromObject(pair.Value, t.Elem())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+		}
+		v.SetMapIndex(key, val)
+	}
+	return v, nil
+}
+
+func hashToStruct(hash *object.Hash, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	for _, f := range structFields(t) {
+		val, ok := hash.Get(&object.String{Value: f.name})
+		if !ok {
+			continue
+		}
+		fv, err := fromObject(val, t.FieldByIndex(f.index).Type)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("field %s: %w", f.name, err)
+		}
+		v.FieldByIndex(f.index).Set(fv)
+	}
+	return v, nil
+}
+
+func instanceToStruct(inst *object.Instance, t reflect.Type) (reflect.Value, error) {
+	return hashToStruct(instanceToHash(inst), t)
+}
+
+func instanceToHash(inst *object.Instance) *object.Hash {
+	hash := object.NewHash()
+	for _, name := range inst.Struct.Fields {
+		hash.Set(&object.String{Value: name}, inst.Fields[name])
+	}
+	return hash
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ponic
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+type point struct {
+	X, Y   int
+	Label  string `ponic:"label"`
+	Hidden bool   `ponic:"-"`
+	secret int
+}
+
+type node struct {
+	Value int
+	Next  *node
+}
+
+func TestToObject(t *testing.T) {
+	shared := &node{Value: 1}
+
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{"int", 42, "42"},
+		{"int8", int8(-8), "-8"},
+		{"uint", uint(7), "7"},
+		{"float", 1.5, "1.5"},
+		{"whole float", float32(2), "2.0"},
+		{"string", "hi", "hi"},
+		{"bool", true, "true"},
+		{"nil", nil, "null"},
+		{"nil pointer", (*node)(nil), "null"},
+		{"nil slice", []int(nil), "[]"},
+		{"slice", []int{1, 2, 3}, "[1, 2, 3]"},
+		{"array", [2]string{"a", "b"}, `["a", "b"]`},
+		{"nested slice", [][]int{{1}, {2, 3}}, "[[1], [2, 3]]"},
+		{"map in key order", map[string]int{"b": 2, "a": 1}, `{"a": 1, "b": 2}`},
+		{"int keys", map[int]bool{2: false, 1: true}, "{1: true, 2: false}"},
+		{"struct", point{X: 1, Y: 2, Label: "p", Hidden: true}, `point{X: 1, Y: 2, label: "p"}`},
+		{"pointer to struct", &node{Value: 1, Next: &node{Value: 2}}, "node{Value: 1, Next: node{Value: 2, Next: null}}"},
+		{"shared pointer", []*node{shared, shared}, "[node{Value: 1, Next: null}, node{Value: 1, Next: null}]"},
+		{"object", &object.Integer{Value: 3}, "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToObject(tt.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got.Inspect() != tt.want {
+				t.Errorf("got %s, want %s", got.Inspect(), tt.want)
+			}
+		})
+	}
+}
+
+func TestToObjectErrors(t *testing.T) {
+	cyclic := &node{Value: 1}
+	cyclic.Next = &node{Value: 2, Next: cyclic}
+
+	selfMap := map[string]interface{}{}
+	selfMap["self"] = selfMap
+
+	selfSlice := []interface{}{nil}
+	selfSlice[0] = selfSlice
+
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{"channel", make(chan int), "cannot convert chan int to a Ponic value"},
+		{"complex", complex(1, 2), "cannot convert complex128 to a Ponic value"},
+		{"unsupported element", []interface{}{1, make(chan int)}, "index 1: cannot convert chan int to a Ponic value"},
+		{"huge uint", uint64(1 << 63), "9223372036854775808 overflows a Ponic integer"},
+		{"unhashable key", map[[1]int]int{{1}: 1}, "map key type [1]int is unusable as a hash key"},
+		{"cyclic pointer", cyclic, "field Next: field Next: cannot convert *ponic.node: the value contains itself"},
+		{"cyclic map", selfMap, "key self: cannot convert map[string]interface {}: the value contains itself"},
+		{"cyclic slice", selfSlice, "index 0: cannot convert []interface {}: the value contains itself"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToObject(tt.in)
+			if err == nil {
+				t.Fatalf("got %s, want error %q", got.Inspect(), tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got error %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	hash := object.NewHash()
+	hash.Set(&object.String{Value: "X"}, &object.Integer{Value: 3})
+	hash.Set(&object.String{Value: "label"}, &object.String{Value: "q"})
+
+	tests := []struct {
+		name   string
+		in     object.Object
+		target interface{}
+		want   interface{}
+	}{
+		{"int", &object.Integer{Value: 5}, new(int), 5},
+		{"int to float", &object.Integer{Value: 5}, new(float64), 5.0},
+		{"float", &object.Float{Value: 0.25}, new(float32), float32(0.25)},
+		{"string", &object.String{Value: "s"}, new(string), "s"},
+		{"bool", &object.Boolean{Value: true}, new(bool), true},
+		{"null to pointer", &object.Null{}, new(*int), (*int)(nil)},
+		{"null to interface", &object.Null{}, new(interface{}), nil},
+		{"int to interface", &object.Integer{Value: 5}, new(interface{}), int64(5)},
+		{"slice", &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}}, new([]int), []int{1, 2}},
+		{"map", hash, new(map[string]interface{}), map[string]interface{}{"X": int64(3), "label": "q"}},
+		{"struct from hash", hash, new(point), point{X: 3, Label: "q"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := FromObject(tt.in, tt.target); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := reflect.ValueOf(tt.target).Elem().Interface()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromObjectErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     object.Object
+		target interface{}
+		want   string
+	}{
+		{"not a pointer", &object.Integer{Value: 1}, 0, "FromObject target must be a non-nil pointer, got int"},
+		{"string to int", &object.String{Value: "1"}, new(int), "cannot use 1 as int"},
+		{"float to int", &object.Float{Value: 1.5}, new(int), "cannot use 1.5 as int"},
+		{"overflow", &object.Integer{Value: 300}, new(int8), "300 overflows int8"},
+		{"negative to uint", &object.Integer{Value: -1}, new(uint), "-1 overflows uint"},
+		{"array length", &object.Array{Elements: []object.Object{&object.Integer{Value: 1}}}, new([2]int), "cannot use array of length 1 as [2]int"},
+		{"element", &object.Array{Elements: []object.Object{&object.Boolean{Value: true}}}, new([]int), "index 0: cannot use true as int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := FromObject(tt.in, tt.target)
+			if err == nil {
+				t.Fatalf("got no error, want %q", tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got error %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
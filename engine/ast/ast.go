@@ -13,6 +13,7 @@ import (
 
 type Node interface {
 	String() string
+	Pos() lexer.Position
 }
 
 type Statement interface {
@@ -32,16 +33,41 @@ type AST struct {
 func (a *AST) String() string {
 	return fmt.Sprintf("AST(%s)", a.Statements)
 }
+func (a *AST) Pos() lexer.Position {
+	if len(a.Statements) > 0 {
+		return a.Statements[0].Pos()
+	}
+	return lexer.Position{}
+}
 
 type Identifier struct {
 	Token *lexer.Token
 	Value string
-}
+	// Depth and Slot are set by the resolver. A local variable lives in
+	// slot Slot of the scope Depth levels out from the one the identifier
+	// is evaluated in. Depth is Global for a variable at the top level of
+	// a module, which is looked up by name, and Unresolved until the
+	// resolver has run.
+	Depth int
+	Slot  int
+}
+
+const (
+	// Global is the Depth of an identifier that names a global.
+	Global = -1
+	// Unresolved is the Depth the parser gives every identifier, so that
+	// running a tree the resolver has not bound fails instead of reading
+	// slot 0.
+	Unresolved = -2
+)
 
 func (i *Identifier) expressionNode() {}
 func (i *Identifier) String() string {
 	return fmt.Sprintf("Identifier(%s)", i.Value)
 }
+func (i *Identifier) Pos() lexer.Position {
+	return i.Token.Pos
+}
 
 type ExpressionStatement struct {
 	Token      *lexer.Token
@@ -52,6 +78,9 @@ func (es *ExpressionStatement) statementNode() {}
 func (es *ExpressionStatement) String() string {
 	return es.Expression.String()
 }
+func (es *ExpressionStatement) Pos() lexer.Position {
+	return es.Token.Pos
+}
 
 type LetStatement struct {
 	Token *lexer.Token
@@ -63,6 +92,9 @@ func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) String() string {
 	return fmt.Sprintf("LetStatement(%s, %s)", ls.Name, ls.Value)
 }
+func (ls *LetStatement) Pos() lexer.Position {
+	return ls.Token.Pos
+}
 
 type ConstStatement struct {
 	Token *lexer.Token
@@ -74,6 +106,9 @@ func (cs *ConstStatement) statementNode() {}
 func (cs *ConstStatement) String() string {
 	return fmt.Sprintf("ConstStatement(%s, %s)", cs.Name, cs.Value)
 }
+func (cs *ConstStatement) Pos() lexer.Position {
+	return cs.Token.Pos
+}
 
 type ReturnStatement struct {
 	Token       *lexer.Token
@@ -84,6 +119,46 @@ func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) String() string {
 	return fmt.Sprintf("ReturnStatement(%s)", rs.ReturnValue)
 }
+func (rs *ReturnStatement) Pos() lexer.Position {
+	return rs.Token.Pos
+}
+
+type BreakStatement struct {
+	Token *lexer.Token
+}
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) String() string {
+	return "BreakStatement()"
+}
+func (bs *BreakStatement) Pos() lexer.Position {
+	return bs.Token.Pos
+}
+
+type ContinueStatement struct {
+	Token *lexer.Token
+}
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) String() string {
+	return "ContinueStatement()"
+}
+func (cs *ContinueStatement) Pos() lexer.Position {
+	return cs.Token.Pos
+}
+
+type YieldStatement struct {
+	Token *lexer.Token
+	Value Expression
+}
+
+func (ys *YieldStatement) statementNode() {}
+func (ys *YieldStatement) String() string {
+	return fmt.Sprintf("YieldStatement(%s)", ys.Value)
+}
+func (ys *YieldStatement) Pos() lexer.Position {
+	return ys.Token.Pos
+}
 
 type UnOp struct {
 	Token    *lexer.Token
@@ -95,6 +170,9 @@ func (uo *UnOp) expressionNode() {}
 func (uo *UnOp) String() string {
 	return fmt.Sprintf("UnOp(%s, %s)", uo.Operator, uo.Right)
 }
+func (uo *UnOp) Pos() lexer.Position {
+	return uo.Token.Pos
+}
 
 type BinOp struct {
 	Token    *lexer.Token
@@ -107,6 +185,9 @@ func (bo *BinOp) expressionNode() {}
 func (bo *BinOp) String() string {
 	return fmt.Sprintf("BinOp(%s, %s, %s)", bo.Left, bo.Operator, bo.Right)
 }
+func (bo *BinOp) Pos() lexer.Position {
+	return bo.Token.Pos
+}
 
 type StringLiteral struct {
 	Token *lexer.Token
@@ -117,6 +198,9 @@ func (sl *StringLiteral) expressionNode() {}
 func (sl *StringLiteral) String() string {
 	return fmt.Sprintf("String(%s)", sl.Value)
 }
+func (sl *StringLiteral) Pos() lexer.Position {
+	return sl.Token.Pos
+}
 
 type IntegerLiteral struct {
 	Token *lexer.Token
@@ -127,6 +211,22 @@ func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) String() string {
 	return fmt.Sprintf("Int(%d)", il.Value)
 }
+func (il *IntegerLiteral) Pos() lexer.Position {
+	return il.Token.Pos
+}
+
+type FloatLiteral struct {
+	Token *lexer.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) String() string {
+	return fmt.Sprintf("Float(%g)", fl.Value)
+}
+func (fl *FloatLiteral) Pos() lexer.Position {
+	return fl.Token.Pos
+}
 
 type BooleanLiteral struct {
 	Token *lexer.Token
@@ -137,6 +237,50 @@ func (b *BooleanLiteral) expressionNode() {}
 func (b *BooleanLiteral) String() string {
 	return fmt.Sprintf("Bool(%t)", b.Value)
 }
+func (b *BooleanLiteral) Pos() lexer.Position {
+	return b.Token.Pos
+}
+
+type ArrayLiteral struct {
+	Token    *lexer.Token
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) String() string {
+	return fmt.Sprintf("Array(%s)", al.Elements)
+}
+func (al *ArrayLiteral) Pos() lexer.Position {
+	return al.Token.Pos
+}
+
+type HashLiteral struct {
+	Token  *lexer.Token
+	Keys   []Expression
+	Values []Expression
+}
+
+func (hl *HashLiteral) expressionNode() {}
+func (hl *HashLiteral) String() string {
+	return fmt.Sprintf("Hash(%s, %s)", hl.Keys, hl.Values)
+}
+func (hl *HashLiteral) Pos() lexer.Position {
+	return hl.Token.Pos
+}
+
+type IndexExpression struct {
+	Token *lexer.Token
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) String() string {
+	return fmt.Sprintf("IndexExpression(%s, %s)", ie.Left, ie.Index)
+}
+func (ie *IndexExpression) Pos() lexer.Position {
+	return ie.Token.Pos
+}
 
 type IfExpression struct {
 	Token       *lexer.Token
@@ -149,6 +293,9 @@ func (ie *IfExpression) expressionNode() {}
 func (ie *IfExpression) String() string {
 	return fmt.Sprintf("IfExpression(%s, %s, %s)", ie.Condition, ie.Consequence, ie.Alternative)
 }
+func (ie *IfExpression) Pos() lexer.Position {
+	return ie.Token.Pos
+}
 
 type BlockStatement struct {
 	Token      *lexer.Token
@@ -159,22 +306,97 @@ func (bs *BlockStatement) statementNode() {}
 func (bs *BlockStatement) String() string {
 	return fmt.Sprintf("BlockStatement(%s)", bs.Statements)
 }
+func (bs *BlockStatement) Pos() lexer.Position {
+	return bs.Token.Pos
+}
 
+// FunctionLiteral is a function expression or declaration. A method
+// declared as fn Receiver.Name(self) {} has Receiver set to the struct name.
 type FunctionLiteral struct {
 	Token      *lexer.Token
 	Parameters []*Identifier
 	Body       *BlockStatement
 	Name       *Identifier
 	Named      bool
+	Generator  bool
+	Receiver   *Identifier
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) String() string {
+	if fl.Receiver != nil {
+		return fmt.Sprintf("MethodLiteral(%s, %s, %s, %s)", fl.Receiver, fl.Name, fl.Parameters, fl.Body)
+	}
 	if fl.Named {
 		return fmt.Sprintf("NamedFunctionLiteral(%s, %s, %s)", fl.Name, fl.Parameters, fl.Body)
 	}
 	return fmt.Sprintf("FunctionLiteral(%s, %s)", fl.Parameters, fl.Body)
 }
+func (fl *FunctionLiteral) Pos() lexer.Position {
+	return fl.Token.Pos
+}
+
+type StructStatement struct {
+	Token  *lexer.Token
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ss *StructStatement) statementNode() {}
+func (ss *StructStatement) String() string {
+	return fmt.Sprintf("StructStatement(%s, %s)", ss.Name, ss.Fields)
+}
+func (ss *StructStatement) Pos() lexer.Position {
+	return ss.Token.Pos
+}
+
+// ImportStatement binds the module loaded from Path to Alias, or to the
+// name of the module when Alias is nil.
+type ImportStatement struct {
+	Token *lexer.Token
+	Path  *StringLiteral
+	Alias *Identifier
+}
+
+func (is *ImportStatement) statementNode() {}
+func (is *ImportStatement) String() string {
+	if is.Alias == nil {
+		return fmt.Sprintf("ImportStatement(%s)", is.Path)
+	}
+	return fmt.Sprintf("ImportStatement(%s, %s)", is.Path, is.Alias)
+}
+func (is *ImportStatement) Pos() lexer.Position {
+	return is.Token.Pos
+}
+
+// ExportStatement wraps a top-level let, const, struct or named function
+// declaration whose binding is visible to modules that import this one.
+type ExportStatement struct {
+	Token     *lexer.Token
+	Statement Statement
+}
+
+func (es *ExportStatement) statementNode() {}
+func (es *ExportStatement) String() string {
+	return fmt.Sprintf("ExportStatement(%s)", es.Statement)
+}
+func (es *ExportStatement) Pos() lexer.Position {
+	return es.Token.Pos
+}
+
+type MemberExpression struct {
+	Token    *lexer.Token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode() {}
+func (me *MemberExpression) String() string {
+	return fmt.Sprintf("MemberExpression(%s, %s)", me.Object, me.Property)
+}
+func (me *MemberExpression) Pos() lexer.Position {
+	return me.Token.Pos
+}
 
 type CallExpression struct {
 	Token     *lexer.Token
@@ -186,6 +408,9 @@ func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) String() string {
 	return fmt.Sprintf("CallExpression(%s, %s)", ce.Function, ce.Arguments)
 }
+func (ce *CallExpression) Pos() lexer.Position {
+	return ce.Function.Pos()
+}
 
 type ForExpression struct {
 	Token         *lexer.Token
@@ -200,3 +425,53 @@ func (fe *ForExpression) expressionNode() {}
 func (fe *ForExpression) String() string {
 	return fmt.Sprintf("ForExpression(%s, %s)", fe.Condition, fe.Body)
 }
+func (fe *ForExpression) Pos() lexer.Position {
+	return fe.Token.Pos
+}
+
+// ForInExpression is `for (value in iterable)` or `for (key, value in
+// iterable)`; Key is nil in the single variable form.
+type ForInExpression struct {
+	Token    *lexer.Token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fe *ForInExpression) expressionNode() {}
+func (fe *ForInExpression) String() string {
+	return fmt.Sprintf("ForInExpression(%s, %s, %s, %s)", fe.Key, fe.Value, fe.Iterable, fe.Body)
+}
+func (fe *ForInExpression) Pos() lexer.Position {
+	return fe.Token.Pos
+}
+
+type TryExpression struct {
+	Token   *lexer.Token
+	Block   *BlockStatement
+	Param   *Identifier
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
+func (te *TryExpression) expressionNode() {}
+func (te *TryExpression) String() string {
+	return fmt.Sprintf("TryExpression(%s, %s, %s, %s)", te.Block, te.Param, te.Catch, te.Finally)
+}
+func (te *TryExpression) Pos() lexer.Position {
+	return te.Token.Pos
+}
+
+type ThrowStatement struct {
+	Token *lexer.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+func (ts *ThrowStatement) String() string {
+	return fmt.Sprintf("ThrowStatement(%s)", ts.Value)
+}
+func (ts *ThrowStatement) Pos() lexer.Position {
+	return ts.Token.Pos
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package compiler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Instructions is a sequence of encoded instructions: an opcode byte
+// followed by its operands, big endian.
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota // push constant [index]
+	OpNull                   // push null
+	OpTrue                   // push true
+	OpFalse                  // push false
+	OpPop                    // discard the top of the stack
+	OpPopN                   // discard [n] values
+	OpDup                    // push the top of the stack again
+
+	OpBinary // apply operator [index into Operators] to the two values on top
+	OpMinus  // negate the top of the stack
+	OpBang   // logical not of the top of the stack
+
+	OpJump        // jump to [offset]
+	OpJumpIfFalse // pop a condition and jump to [offset] if it is falsy
+
+	OpGetGlobal // push the value of global [slot]
+	OpSetGlobal // pop a value into the already defined global [slot]
+	OpDefGlobal // pop a value and define global [slot] with it
+	OpGetLocal  // push the value of local [slot]
+	OpSetLocal  // pop a value into the already defined local [slot]
+	OpDefLocal  // pop a value into a new variable in local [slot]
+	OpNewLocal  // create local [slot] without a value, for a hoisted declaration
+	OpGetFree   // push the value of captured variable [index]
+	OpSetFree   // pop a value into captured variable [index]
+	OpGetName   // push the global or builtin called constant [index]
+	OpSetName   // pop a value into the global called constant [index]
+
+	OpLoadCell     // push the variable of local [slot], for a closure to capture
+	OpLoadFreeCell // push captured variable [index], for a closure to capture
+	OpClosure      // pop [n] variables and close function constant [index] over them
+
+	OpCall   // call the function below [argc] arguments
+	OpReturn // return the top of the stack from the current function
+	OpExit   // end the program with a top-level return of the top of the stack
+
+	OpArray  // pop [n] elements into an array
+	OpHash   // pop [n] key and value pairs into a hash
+	OpIndex  // pop a container and an index and push the element
+	OpSetIdx // pop a container, an index and a value and assign with operator [index]
+	OpMember // pop a value and push its member called constant [index]
+	OpSetMem // pop a value and a new value for its member constant [index], assigned with operator [index]
+
+	OpIter     // replace the top of the stack with an iterator over it
+	OpIterNext // advance the iterator on top, pushing [1 with key] values, or jump to [offset] when done
+	OpIterEnd  // pop the iterator on top and close it
+
+	OpStruct // push a new struct type like the one in constant [index]
+	OpMethod // pop a struct type and a function and declare the function as its method constant [index]
+
+	OpCatch   // start a try block whose errors jump to [offset] with the caught value
+	OpFinally // start a try block whose unwinding jumps to [offset] with what unwinds it
+	OpEndTry  // end the innermost try block
+	OpThrow   // pop a value and throw it
+	OpRethrow // pop what a finally block interrupted and carry on unwinding it
+
+	OpYield // pop a value and suspend the generator, handing the value to its consumer
+
+	OpImport // push the module at the path in constant [index]
+)
+
+// Operators are the operators of OpBinary, OpSetIdx and OpSetMem, which
+// encode them as an index into this table.
+var Operators = []string{"+", "-", "*", "/", "%", "<", ">", "<=", ">=", "==", "!=", "="}
+
+func operatorIndex(operator string) int {
+	for i, op := range Operators {
+		if op == operator {
+			return i
+		}
+	}
+	panic("compiler: unknown operator " + operator)
+}
+
+// Definition names an opcode and gives the width in bytes of each of its
+// operands.
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpNull:     {"OpNull", nil},
+	OpTrue:     {"OpTrue", nil},
+	OpFalse:    {"OpFalse", nil},
+	OpPop:      {"OpPop", nil},
+	OpPopN:     {"OpPopN", []int{2}},
+	OpDup:      {"OpDup", nil},
+
+	OpBinary: {"OpBinary", []int{1}},
+	OpMinus:  {"OpMinus", nil},
+	OpBang:   {"OpBang", nil},
+
+	OpJump:        {"OpJump", []int{2}},
+	OpJumpIfFalse: {"OpJumpIfFalse", []int{2}},
+
+	OpGetGlobal: {"OpGetGlobal", []int{2}},
+	OpSetGlobal: {"OpSetGlobal", []int{2}},
+	OpDefGlobal: {"OpDefGlobal", []int{2}},
+	OpGetLocal:  {"OpGetLocal", []int{1}},
+	OpSetLocal:  {"OpSetLocal", []int{1}},
+	OpDefLocal:  {"OpDefLocal", []int{1}},
+	OpNewLocal:  {"OpNewLocal", []int{1}},
+	OpGetFree:   {"OpGetFree", []int{1}},
+	OpSetFree:   {"OpSetFree", []int{1}},
+	OpGetName:   {"OpGetName", []int{2}},
+	OpSetName:   {"OpSetName", []int{2}},
+
+	OpLoadCell:     {"OpLoadCell", []int{1}},
+	OpLoadFreeCell: {"OpLoadFreeCell", []int{1}},
+	OpClosure:      {"OpClosure", []int{2, 1}},
+
+	OpCall:   {"OpCall", []int{1}},
+	OpReturn: {"OpReturn", nil},
+	OpExit:   {"OpExit", nil},
+
+	OpArray:  {"OpArray", []int{2}},
+	OpHash:   {"OpHash", []int{2}},
+	OpIndex:  {"OpIndex", nil},
+	OpSetIdx: {"OpSetIdx", []int{1}},
+	OpMember: {"OpMember", []int{2}},
+	OpSetMem: {"OpSetMem", []int{2, 1}},
+
+	OpIter:     {"OpIter", nil},
+	OpIterNext: {"OpIterNext", []int{2, 1}},
+	OpIterEnd:  {"OpIterEnd", nil},
+
+	OpStruct: {"OpStruct", []int{2}},
+	OpMethod: {"OpMethod", []int{2}},
+
+	OpCatch:   {"OpCatch", []int{2}},
+	OpFinally: {"OpFinally", []int{2}},
+	OpEndTry:  {"OpEndTry", nil},
+	OpThrow:   {"OpThrow", nil},
+	OpRethrow: {"OpRethrow", nil},
+
+	OpYield: {"OpYield", nil},
+
+	OpImport: {"OpImport", []int{2}},
+}
+
+func Lookup(op Opcode) (*Definition, error) {
+	def, ok := definitions[op]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes one instruction. Operands are truncated to their width; the
+// compiler checks that they fit before calling it.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		switch def.OperandWidths[i] {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += def.OperandWidths[i]
+	}
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction of def and returns
+// them with the number of bytes they take up.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ins[offset])
+		}
+		offset += width
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// String disassembles the instructions, one per line.
+func (ins Instructions) String() string {
+	var out strings.Builder
+
+	for i := 0; i < len(ins); {
+		def, err := Lookup(Opcode(ins[i]))
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s", i, def.Name)
+		for _, o := range operands {
+			fmt.Fprintf(&out, " %d", o)
+		}
+		out.WriteString("\n")
+
+		i += 1 + read
+	}
+	return out.String()
+}
@@ -0,0 +1,967 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package compiler lowers a Ponic syntax tree to bytecode for the vm.
+//
+// Every statement compiles to code that leaves exactly one value on the
+// stack, the value the tree-walking runtime gives it, so that blocks,
+// if expressions and function bodies yield the value of their last
+// statement. Variables of functions and blocks live in slots of the
+// frame; top-level variables are globals, declared up front so that
+// functions can refer to ones defined after them.
+//
+// A try block registers a handler with the vm, which jumps to it when the
+// block fails or is otherwise unwound. A return, break or continue that
+// leaves a try block instead ends the handler itself and runs a copy of the
+// finally block compiled in place.
+package compiler
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/lexer"
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+// Function is a compiled function, or the top-level code of a module.
+type Function struct {
+	Name         string
+	Instructions Instructions
+	NumParams    int
+	NumLocals    int
+	// Generator is set on a function containing yield, whose calls return
+	// a generator rather than running the body
+	Generator bool
+	// Positions maps the instructions that can fail to the source they
+	// were compiled from, ordered by offset.
+	Positions []Position
+	Constants []object.Object
+}
+
+func (f *Function) Type() object.Type {
+	return object.FUNCTION
+}
+func (f *Function) Inspect() string {
+	return "function"
+}
+func (f *Function) String() string {
+	return fmt.Sprintf("CompiledFunction(%s)", f.Name)
+}
+
+// Position is the source position of the instruction at Offset. For a
+// call, Callee names the function called, for stack traces. For a load or
+// an assignment of a local variable, Variable names the variable, for the
+// error raised when it runs before the variable is declared.
+type Position struct {
+	Offset   int
+	Pos      lexer.Position
+	Callee   string
+	Variable string
+}
+
+// PositionAt returns the position of the instruction at offset.
+func (f *Function) PositionAt(offset int) Position {
+	i := sort.Search(len(f.Positions), func(i int) bool { return f.Positions[i].Offset >= offset })
+	if i < len(f.Positions) && f.Positions[i].Offset == offset {
+		return f.Positions[i]
+	}
+	return Position{Offset: offset}
+}
+
+// Program is the result of compiling a module.
+type Program struct {
+	Main      *Function
+	Constants []object.Object
+	// Exports lists the names of the globals the module exports.
+	Exports []string
+}
+
+// Error is a construct the compiler rejects.
+type Error struct {
+	Message string
+	Pos     lexer.Position
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Message)
+}
+
+type loop struct {
+	depth     int
+	breaks    []int
+	continues []int
+}
+
+// try is a try expression whose block or catch clause is being compiled.
+type try struct {
+	finally *ast.BlockStatement
+	// handlers is the number of handlers the try has started and not ended
+	handlers int
+	// loops is the number of loops of the function the try is in
+	loops int
+}
+
+// function is the state of the function being compiled.
+type function struct {
+	*funcScope
+	fn *Function
+	// depth is the number of values on the stack of the frame at the
+	// current instruction
+	depth int
+	loops []*loop
+	tries []*try
+	// parent is the function the function is nested in
+	parent *function
+}
+
+type compiler struct {
+	globals   *Globals
+	constants []object.Object
+	functions []*Function
+	exports   []string
+	current   *function
+}
+
+// compileError unwinds the compiler; Compile recovers it.
+type compileError struct {
+	err *Error
+}
+
+// Compile compiles program as the top-level code of the module whose
+// globals are globals.
+func Compile(program *ast.AST, globals *Globals) (prog *Program, err error) {
+	c := &compiler{globals: globals}
+
+	defer func() {
+		if r := recover(); r != nil {
+			ce, ok := r.(compileError)
+			if !ok {
+				panic(r)
+			}
+			prog, err = nil, ce.err
+		}
+	}()
+
+	for _, stmt := range program.Statements {
+		if name := declaredName(stmt); name != "" {
+			globals.declare(name)
+		}
+	}
+
+	main := c.enterFunction("main", 0)
+	c.statements(program.Statements)
+	c.emit(OpReturn)
+	c.leaveFunction()
+
+	for _, fn := range c.functions {
+		fn.Constants = c.constants
+	}
+	return &Program{Main: main, Constants: c.constants, Exports: c.exports}, nil
+}
+
+// declaredName returns the name a top-level statement declares, if any.
+func declaredName(stmt ast.Statement) string {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Name.Value
+	case *ast.ConstStatement:
+		return stmt.Name.Value
+	case *ast.StructStatement:
+		return stmt.Name.Value
+	case *ast.ImportStatement:
+		return importName(stmt)
+	case *ast.ExportStatement:
+		return declaredName(stmt.Statement)
+	case *ast.ExpressionStatement:
+		if fl, ok := stmt.Expression.(*ast.FunctionLiteral); ok && fl.Named && fl.Receiver == nil {
+			return fl.Name.Value
+		}
+	}
+	return ""
+}
+
+// importName is the name an import statement binds: its alias, or the name
+// of the module.
+func importName(is *ast.ImportStatement) string {
+	if is.Alias != nil {
+		return is.Alias.Value
+	}
+	path := is.Path.Value
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+func (c *compiler) fail(pos lexer.Position, format string, args ...interface{}) {
+	panic(compileError{&Error{Message: fmt.Sprintf(format, args...), Pos: pos}})
+}
+
+func (c *compiler) enterFunction(name string, numParams int) *Function {
+	var outer *funcScope
+	if c.current != nil {
+		outer = c.current.funcScope
+	}
+
+	fn := &Function{Name: name, NumParams: numParams}
+	c.functions = append(c.functions, fn)
+	c.current = &function{funcScope: newFuncScope(outer), fn: fn, parent: c.current}
+	return fn
+}
+
+// leaveFunction finishes the current function and returns the variables
+// it captures.
+func (c *compiler) leaveFunction() []*symbol {
+	f := c.current
+	f.fn.NumLocals = f.numLocals
+	if f.numLocals > 255 {
+		c.fail(lexer.Position{}, "function %s has more than 255 variables", f.fn.Name)
+	}
+	if len(f.free) > 255 {
+		c.fail(lexer.Position{}, "function %s captures more than 255 variables", f.fn.Name)
+	}
+
+	c.current = f.parent
+	return f.free
+}
+
+// topLevel reports whether declarations at the current position are
+// globals.
+func (c *compiler) topLevel() bool {
+	return c.current.outer == nil && len(c.current.blocks) == 0
+}
+
+func (c *compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	if len(c.constants) > 1<<16 {
+		c.fail(lexer.Position{}, "too many constants")
+	}
+	return len(c.constants) - 1
+}
+
+func (c *compiler) stringConstant(s string) int {
+	return c.addConstant(&object.String{Value: s})
+}
+
+// emit appends an instruction to the current function and returns its
+// offset.
+func (c *compiler) emit(op Opcode, operands ...int) int {
+	c.checkOperands(op, operands)
+	f := c.current
+	offset := len(f.fn.Instructions)
+	f.fn.Instructions = append(f.fn.Instructions, Make(op, operands...)...)
+	f.depth += stackEffect(op, operands)
+	return offset
+}
+
+// emitAt emits an instruction that can fail at pos.
+func (c *compiler) emitAt(pos lexer.Position, op Opcode, operands ...int) int {
+	offset := c.emit(op, operands...)
+	c.current.fn.Positions = append(c.current.fn.Positions, Position{Offset: offset, Pos: pos})
+	return offset
+}
+
+// emitVariable emits an instruction that accesses the local variable
+// ident, recording its name.
+func (c *compiler) emitVariable(ident *ast.Identifier, op Opcode, operands ...int) int {
+	offset := c.emit(op, operands...)
+	c.current.fn.Positions = append(c.current.fn.Positions, Position{Offset: offset, Pos: ident.Pos(), Variable: ident.Value})
+	return offset
+}
+
+// stackEffect is the change in the number of values on the stack an
+// instruction makes, on the path that falls through to the next one.
+func stackEffect(op Opcode, operands []int) int {
+	switch op {
+	case OpConstant, OpNull, OpTrue, OpFalse, OpDup, OpGetGlobal, OpGetLocal, OpGetFree,
+		OpGetName, OpLoadCell, OpLoadFreeCell, OpStruct, OpImport:
+		return 1
+	case OpPop, OpBinary, OpJumpIfFalse, OpSetGlobal, OpDefGlobal, OpSetLocal, OpDefLocal,
+		OpSetFree, OpSetName, OpIndex, OpReturn, OpExit, OpIterEnd, OpThrow, OpRethrow, OpYield:
+		return -1
+	case OpPopN:
+		return -operands[0]
+	case OpClosure:
+		return 1 - operands[1]
+	case OpCall:
+		return -operands[0]
+	case OpArray:
+		return 1 - operands[0]
+	case OpHash:
+		return 1 - 2*operands[0]
+	case OpSetIdx:
+		return -3
+	case OpSetMem, OpMethod:
+		return -2
+	case OpIterNext:
+		return 1 + operands[1]
+	default:
+		return 0
+	}
+}
+
+// changeOperand rewrites the first operand of the instruction at offset,
+// for jumps whose target was not known when they were emitted.
+func (c *compiler) changeOperand(offset int, operand int) {
+	ins := c.current.fn.Instructions
+	op := Opcode(ins[offset])
+	operands := append([]int{operand}, c.operandsAt(offset)[1:]...)
+	c.checkOperands(op, operands)
+	copy(ins[offset:], Make(op, operands...))
+}
+
+// checkOperands fails if a 16-bit operand does not fit, which Make would
+// silently truncate: a jump target in a function whose code is longer than
+// 64 KiB, or a count or slot that grows too large. 8-bit operands, such as
+// local slots and argument counts, are checked where they are counted.
+func (c *compiler) checkOperands(op Opcode, operands []int) {
+	def := definitions[op]
+	for i, operand := range operands {
+		if def.OperandWidths[i] != 2 || operand <= 1<<16-1 {
+			continue
+		}
+		switch op {
+		case OpJump, OpJumpIfFalse, OpIterNext, OpCatch, OpFinally:
+			c.fail(lexer.Position{}, "function %s is too long: its code exceeds %d bytes", c.current.fn.Name, 1<<16-1)
+		default:
+			c.fail(lexer.Position{}, "function %s is too large: operand %d of %s exceeds %d", c.current.fn.Name, operand, def.Name, 1<<16-1)
+		}
+	}
+}
+
+func (c *compiler) operandsAt(offset int) []int {
+	ins := c.current.fn.Instructions
+	def, _ := Lookup(Opcode(ins[offset]))
+	operands, _ := ReadOperands(def, ins[offset+1:])
+	return operands
+}
+
+func (c *compiler) here() int {
+	return len(c.current.fn.Instructions)
+}
+
+// popTo discards the values above depth, for jumping out of a loop.
+func (c *compiler) popTo(depth int) {
+	if n := c.current.depth - depth; n > 0 {
+		c.emit(OpPopN, n)
+		c.current.depth += n
+	}
+}
+
+// statements compiles a sequence of statements, leaving the value of the
+// last one, or null if there are none.
+func (c *compiler) statements(stmts []ast.Statement) {
+	c.hoist(stmts)
+	if len(stmts) == 0 {
+		c.emit(OpNull)
+		return
+	}
+	for i, stmt := range stmts {
+		if i > 0 {
+			c.emit(OpPop)
+		}
+		c.statement(stmt)
+	}
+}
+
+// hoist declares the locals that stmts declare before compiling any of
+// them, as the resolver does, so that a function can refer to a variable
+// of its block declared after it. Each gets a new cell without a value
+// every time the block is entered; its declaration stores into that cell.
+func (c *compiler) hoist(stmts []ast.Statement) {
+	if c.topLevel() {
+		return
+	}
+	for _, stmt := range stmts {
+		name, constant := localDeclaration(stmt)
+		if name == "" {
+			continue
+		}
+		sym := c.current.defineLocal(name, constant)
+		c.emit(OpNewLocal, sym.index)
+	}
+}
+
+// localDeclaration returns the name a statement in a block declares, if
+// any, and whether it is a constant.
+func localDeclaration(stmt ast.Statement) (string, bool) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Name.Value, false
+	case *ast.ConstStatement:
+		return stmt.Name.Value, true
+	case *ast.StructStatement:
+		return stmt.Name.Value, true
+	case *ast.ExpressionStatement:
+		if fl, ok := stmt.Expression.(*ast.FunctionLiteral); ok && fl.Named && fl.Receiver == nil {
+			return fl.Name.Value, false
+		}
+	}
+	return "", false
+}
+
+func (c *compiler) block(block *ast.BlockStatement) {
+	c.current.enterBlock()
+	c.statements(block.Statements)
+	c.current.leaveBlock()
+}
+
+func (c *compiler) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		c.expression(stmt.Expression)
+	case *ast.LetStatement:
+		c.expression(stmt.Value)
+		c.define(stmt.Name, false)
+		c.emit(OpNull)
+	case *ast.ConstStatement:
+		c.expression(stmt.Value)
+		c.define(stmt.Name, true)
+		c.emit(OpNull)
+	case *ast.ReturnStatement:
+		c.expression(stmt.ReturnValue)
+		c.leaveTries(0)
+		if c.current.outer == nil {
+			c.emit(OpExit)
+		} else {
+			c.emit(OpReturn)
+		}
+		// the code that follows is unreachable; keep the depth as if the
+		// statement had a value
+		c.current.depth++
+	case *ast.BreakStatement:
+		l := c.current.loops[len(c.current.loops)-1]
+		c.leaveTries(c.loopTries())
+		c.popTo(l.depth)
+		l.breaks = append(l.breaks, c.emit(OpJump, 0))
+		c.current.depth++
+	case *ast.ContinueStatement:
+		l := c.current.loops[len(c.current.loops)-1]
+		c.leaveTries(c.loopTries())
+		c.popTo(l.depth)
+		l.continues = append(l.continues, c.emit(OpJump, 0))
+		c.current.depth++
+	case *ast.BlockStatement:
+		c.block(stmt)
+	case *ast.ImportStatement:
+		c.emitAt(stmt.Pos(), OpImport, c.stringConstant(stmt.Path.Value))
+		sym := c.globals.declare(importName(stmt))
+		c.defineGlobal(sym, false, stmt.Pos())
+		c.emit(OpNull)
+	case *ast.ExportStatement:
+		c.statement(stmt.Statement)
+		c.exports = append(c.exports, declaredName(stmt.Statement))
+	case *ast.StructStatement:
+		c.structStatement(stmt)
+	case *ast.ThrowStatement:
+		c.expression(stmt.Value)
+		c.emitAt(stmt.Pos(), OpThrow)
+		// unreachable code follows, as after a return
+		c.current.depth++
+	case *ast.YieldStatement:
+		c.expression(stmt.Value)
+		c.emit(OpYield)
+		c.emit(OpNull)
+	default:
+		c.fail(stmt.Pos(), "unsupported statement %s", stmt)
+	}
+}
+
+// name returns the name of the variable ident. Only a tree the resolver has
+// checked is compiled, so ident must have been resolved.
+func (c *compiler) name(ident *ast.Identifier) string {
+	if ident.Depth == ast.Unresolved {
+		c.fail(ident.Pos(), "%s was not resolved", ident.Value)
+	}
+	return ident.Value
+}
+
+// define pops the value on top of the stack into a new variable.
+func (c *compiler) define(ident *ast.Identifier, constant bool) {
+	name := c.name(ident)
+	if c.topLevel() {
+		c.defineGlobal(c.globals.declare(name), constant, ident.Pos())
+		return
+	}
+
+	if sym, ok := c.current.declared(name); ok {
+		c.emit(OpSetLocal, sym.index)
+		return
+	}
+	sym := c.current.defineLocal(name, constant)
+	c.emit(OpDefLocal, sym.index)
+}
+
+func (c *compiler) defineGlobal(sym *symbol, constant bool, pos lexer.Position) {
+	if c.globals.Constant[sym.index] {
+		c.fail(pos, "Cannot reassign constant %s", sym.name)
+	}
+	c.globals.Constant[sym.index] = constant
+	c.emitAt(pos, OpDefGlobal, sym.index)
+}
+
+func (c *compiler) resolve(name string) *symbol {
+	if sym, ok := c.current.resolve(name); ok {
+		return sym
+	}
+	if sym, ok := c.globals.symbols[name]; ok {
+		return sym
+	}
+	return &symbol{name: name, kind: nameSymbol}
+}
+
+func (c *compiler) load(ident *ast.Identifier) {
+	sym := c.resolve(c.name(ident))
+	switch sym.kind {
+	case globalSymbol:
+		c.emitAt(ident.Pos(), OpGetGlobal, sym.index)
+	case localSymbol:
+		c.emitVariable(ident, OpGetLocal, sym.index)
+	case freeSymbol:
+		c.emitVariable(ident, OpGetFree, sym.index)
+	default:
+		c.emitAt(ident.Pos(), OpGetName, c.stringConstant(ident.Value))
+	}
+}
+
+// store pops the value on top of the stack into the variable ident.
+func (c *compiler) store(ident *ast.Identifier) {
+	sym := c.resolve(c.name(ident))
+	if sym.constant {
+		c.fail(ident.Pos(), "Cannot reassign constant %s", ident.Value)
+	}
+
+	switch sym.kind {
+	case globalSymbol:
+		c.emitAt(ident.Pos(), OpSetGlobal, sym.index)
+	case localSymbol:
+		c.emitVariable(ident, OpSetLocal, sym.index)
+	case freeSymbol:
+		c.emitVariable(ident, OpSetFree, sym.index)
+	default:
+		c.emitAt(ident.Pos(), OpSetName, c.stringConstant(ident.Value))
+	}
+}
+
+func (c *compiler) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		c.emit(OpConstant, c.addConstant(&object.Integer{Value: exp.Value}))
+	case *ast.FloatLiteral:
+		c.emit(OpConstant, c.addConstant(&object.Float{Value: exp.Value}))
+	case *ast.StringLiteral:
+		c.emit(OpConstant, c.stringConstant(exp.Value))
+	case *ast.BooleanLiteral:
+		if exp.Value {
+			c.emit(OpTrue)
+		} else {
+			c.emit(OpFalse)
+		}
+	case *ast.Identifier:
+		c.load(exp)
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			c.expression(el)
+		}
+		c.emit(OpArray, len(exp.Elements))
+	case *ast.HashLiteral:
+		for i, key := range exp.Keys {
+			c.expression(key)
+			c.expression(exp.Values[i])
+		}
+		c.emitAt(exp.Pos(), OpHash, len(exp.Keys))
+	case *ast.IndexExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Index)
+		c.emitAt(exp.Pos(), OpIndex)
+	case *ast.MemberExpression:
+		c.expression(exp.Object)
+		c.emitAt(exp.Pos(), OpMember, c.stringConstant(exp.Property.Value))
+	case *ast.UnOp:
+		c.expression(exp.Right)
+		switch exp.Operator {
+		case "-":
+			c.emitAt(exp.Pos(), OpMinus)
+		case "!":
+			c.emit(OpBang)
+		default:
+			c.fail(exp.Pos(), "unknown operator %s", exp.Operator)
+		}
+	case *ast.BinOp:
+		c.binOp(exp)
+	case *ast.IfExpression:
+		c.ifExpression(exp)
+	case *ast.ForExpression:
+		c.forExpression(exp)
+	case *ast.ForInExpression:
+		c.forInExpression(exp)
+	case *ast.FunctionLiteral:
+		c.functionLiteral(exp)
+	case *ast.CallExpression:
+		c.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			c.expression(arg)
+		}
+		if len(exp.Arguments) > 255 {
+			c.fail(exp.Pos(), "too many arguments")
+		}
+		offset := c.emitAt(exp.Pos(), OpCall, len(exp.Arguments))
+		positions := c.current.fn.Positions
+		positions[len(positions)-1] = Position{Offset: offset, Pos: exp.Pos(), Callee: calleeName(exp.Function)}
+	case *ast.TryExpression:
+		c.tryExpression(exp)
+	default:
+		c.fail(exp.Pos(), "unsupported expression %s", exp)
+	}
+}
+
+func calleeName(exp ast.Expression) string {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return exp.Value
+	case *ast.MemberExpression:
+		return calleeName(exp.Object) + "." + exp.Property.Value
+	default:
+		return "<anonymous>"
+	}
+}
+
+func (c *compiler) binOp(exp *ast.BinOp) {
+	switch exp.Operator {
+	case "=", "+=", "-=", "*=", "/=", "%=":
+		c.assign(exp)
+	case "&&", "||":
+		// leave the left operand as the result if it decides it, else
+		// replace it with the right one
+		c.expression(exp.Left)
+		c.emit(OpDup)
+		if exp.Operator == "&&" {
+			end := c.emit(OpJumpIfFalse, 0)
+			c.emit(OpPop)
+			c.expression(exp.Right)
+			c.changeOperand(end, c.here())
+		} else {
+			right := c.emit(OpJumpIfFalse, 0)
+			end := c.emit(OpJump, 0)
+			c.changeOperand(right, c.here())
+			c.emit(OpPop)
+			c.expression(exp.Right)
+			c.changeOperand(end, c.here())
+		}
+	default:
+		c.expression(exp.Left)
+		c.expression(exp.Right)
+		c.emitAt(exp.Pos(), OpBinary, operatorIndex(exp.Operator))
+	}
+}
+
+func (c *compiler) assign(exp *ast.BinOp) {
+	op := strings.TrimSuffix(exp.Operator, "=")
+	// OpSetIdx and OpSetMem take the operator of a compound assignment, or
+	// = for a plain one
+	assignOp := operatorIndex("=")
+	if op != "" {
+		assignOp = operatorIndex(op)
+	}
+
+	switch left := exp.Left.(type) {
+	case *ast.Identifier:
+		if op != "" {
+			c.load(left)
+			c.expression(exp.Right)
+			c.emitAt(exp.Pos(), OpBinary, operatorIndex(op))
+		} else {
+			c.expression(exp.Right)
+		}
+		c.store(left)
+	case *ast.IndexExpression:
+		c.expression(left.Left)
+		c.expression(left.Index)
+		c.expression(exp.Right)
+		c.emitAt(exp.Pos(), OpSetIdx, assignOp)
+	case *ast.MemberExpression:
+		c.expression(left.Object)
+		c.expression(exp.Right)
+		c.emitAt(exp.Pos(), OpSetMem, c.stringConstant(left.Property.Value), assignOp)
+	default:
+		c.fail(exp.Pos(), "cannot assign to %s", exp.Left)
+	}
+	c.emit(OpNull)
+}
+
+func (c *compiler) ifExpression(exp *ast.IfExpression) {
+	c.expression(exp.Condition)
+	alternative := c.emit(OpJumpIfFalse, 0)
+
+	c.block(exp.Consequence)
+	end := c.emit(OpJump, 0)
+	c.current.depth--
+
+	c.changeOperand(alternative, c.here())
+	if exp.Alternative != nil {
+		c.block(exp.Alternative)
+	} else {
+		c.emit(OpNull)
+	}
+	c.changeOperand(end, c.here())
+}
+
+func (c *compiler) enterLoop() *loop {
+	l := &loop{depth: c.current.depth}
+	c.current.loops = append(c.current.loops, l)
+	return l
+}
+
+// leaveLoop points the breaks of the loop at the current position and its
+// continues at target.
+func (c *compiler) leaveLoop(target int) {
+	loops := c.current.loops
+	l := loops[len(loops)-1]
+	c.current.loops = loops[:len(loops)-1]
+
+	for _, offset := range l.breaks {
+		c.changeOperand(offset, c.here())
+	}
+	for _, offset := range l.continues {
+		c.changeOperand(offset, target)
+	}
+}
+
+func (c *compiler) forExpression(exp *ast.ForExpression) {
+	c.current.enterBlock()
+
+	if !exp.ConditionOnly {
+		c.statement(exp.Initializer)
+		c.emit(OpPop)
+	}
+
+	c.enterLoop()
+	start := c.here()
+	c.expression(exp.Condition)
+	exit := c.emit(OpJumpIfFalse, 0)
+
+	c.block(exp.Body)
+	c.emit(OpPop)
+
+	post := c.here()
+	if !exp.ConditionOnly && exp.Post != nil {
+		c.statement(exp.Post)
+		c.emit(OpPop)
+	}
+	c.emit(OpJump, start)
+
+	c.changeOperand(exit, c.here())
+	c.leaveLoop(post)
+	c.emit(OpNull)
+
+	c.current.leaveBlock()
+}
+
+func (c *compiler) forInExpression(exp *ast.ForInExpression) {
+	c.expression(exp.Iterable)
+	c.emitAt(exp.Iterable.Pos(), OpIter)
+
+	l := c.enterLoop()
+	hasKey := 0
+	if exp.Key != nil {
+		hasKey = 1
+	}
+	next := c.emitAt(exp.Pos(), OpIterNext, 0, hasKey)
+
+	c.current.enterBlock()
+	value := c.current.defineLocal(c.name(exp.Value), false)
+	c.emit(OpDefLocal, value.index)
+	if exp.Key != nil {
+		key := c.current.defineLocal(c.name(exp.Key), false)
+		c.emit(OpDefLocal, key.index)
+	}
+	c.block(exp.Body)
+	c.emit(OpPop)
+	c.current.leaveBlock()
+	c.emit(OpJump, next)
+
+	// OpIterNext jumps here with the iterator still on the stack
+	c.changeOperand(next, c.here())
+	c.current.depth = l.depth
+	c.leaveLoop(next)
+	c.emit(OpIterEnd)
+	c.emit(OpNull)
+}
+
+func (c *compiler) functionLiteral(fl *ast.FunctionLiteral) {
+	if fl.Receiver != nil {
+		c.method(fl)
+		return
+	}
+
+	name := "<anonymous>"
+	if fl.Named {
+		name = c.name(fl.Name)
+	}
+
+	// a named function is bound before its body is compiled, so that the
+	// body can call it; one declared directly in a block has been hoisted
+	var local *symbol
+	if fl.Named && !c.topLevel() {
+		var ok bool
+		if local, ok = c.current.declared(fl.Name.Value); !ok {
+			local = c.current.defineLocal(fl.Name.Value, false)
+			c.emit(OpNewLocal, local.index)
+		}
+	}
+
+	c.closure(name, fl)
+
+	if !fl.Named {
+		return
+	}
+	if local != nil {
+		c.emit(OpSetLocal, local.index)
+	} else {
+		c.defineGlobal(c.globals.declare(fl.Name.Value), false, fl.Name.Pos())
+	}
+	c.emit(OpNull)
+}
+
+// closure compiles fl as a function called name and pushes a closure of it.
+func (c *compiler) closure(name string, fl *ast.FunctionLiteral) {
+	fn := c.enterFunction(name, len(fl.Parameters))
+	fn.Generator = fl.Generator
+	c.current.enterBlock()
+	for _, param := range fl.Parameters {
+		c.current.defineLocal(c.name(param), false)
+	}
+	c.statements(fl.Body.Statements)
+	c.emit(OpReturn)
+	c.current.leaveBlock()
+	free := c.leaveFunction()
+
+	for _, sym := range free {
+		switch sym.kind {
+		case localSymbol:
+			c.emit(OpLoadCell, sym.index)
+		case freeSymbol:
+			c.emit(OpLoadFreeCell, sym.index)
+		}
+	}
+	c.emit(OpClosure, c.addConstant(fn), len(free))
+}
+
+// method declares fl as a method of the struct its receiver names. Methods
+// are not bound to a variable; they are reached through instances.
+func (c *compiler) method(fl *ast.FunctionLiteral) {
+	c.closure(fl.Receiver.Value+"."+fl.Name.Value, fl)
+	c.load(fl.Receiver)
+	c.emitAt(fl.Name.Pos(), OpMethod, c.stringConstant(fl.Name.Value))
+	c.emit(OpNull)
+}
+
+func (c *compiler) structStatement(stmt *ast.StructStatement) {
+	st := &object.StructType{Name: stmt.Name.Value}
+	for _, field := range stmt.Fields {
+		for _, seen := range st.Fields {
+			if seen == field.Value {
+				c.fail(field.Pos(), "duplicate field %s in struct %s", field.Value, st.Name)
+			}
+		}
+		st.Fields = append(st.Fields, field.Value)
+	}
+
+	c.emit(OpStruct, c.addConstant(st))
+	c.define(stmt.Name, true)
+	c.emit(OpNull)
+}
+
+// tryExpression compiles a try expression, whose value is that of its
+// block, or of its catch clause if the block fails. The finally block is
+// compiled twice: once for when the rest of the try ends normally and once
+// for the handler, which carries on unwinding once the block has run.
+func (c *compiler) tryExpression(exp *ast.TryExpression) {
+	t := &try{finally: exp.Finally, loops: len(c.current.loops)}
+	c.current.tries = append(c.current.tries, t)
+	depth := c.current.depth
+
+	var finally, catch int
+	if exp.Finally != nil {
+		finally = c.emit(OpFinally, 0)
+		t.handlers++
+	}
+	if exp.Catch != nil {
+		catch = c.emit(OpCatch, 0)
+		t.handlers++
+	}
+	c.block(exp.Block)
+
+	if exp.Catch != nil {
+		c.emit(OpEndTry)
+		t.handlers--
+		end := c.emit(OpJump, 0)
+
+		// the vm jumps here with the caught value on the stack
+		c.changeOperand(catch, c.here())
+		c.current.depth = depth + 1
+		c.current.enterBlock()
+		if exp.Param != nil {
+			param := c.current.defineLocal(c.name(exp.Param), false)
+			c.emit(OpDefLocal, param.index)
+		} else {
+			c.emit(OpPop)
+		}
+		c.statements(exp.Catch.Statements)
+		c.current.leaveBlock()
+		c.changeOperand(end, c.here())
+	}
+
+	c.current.tries = c.current.tries[:len(c.current.tries)-1]
+	if exp.Finally == nil {
+		return
+	}
+
+	c.emit(OpEndTry)
+	c.block(exp.Finally)
+	c.emit(OpPop)
+	end := c.emit(OpJump, 0)
+
+	// the vm jumps here with what unwinds the try on the stack
+	c.changeOperand(finally, c.here())
+	c.current.depth = depth + 1
+	c.block(exp.Finally)
+	c.emit(OpPop)
+	c.emit(OpRethrow)
+	c.current.depth = depth + 1
+	c.changeOperand(end, c.here())
+}
+
+// loopTries returns the index of the outermost try expression of the
+// current function that is inside its innermost loop, for a break or
+// continue of the loop.
+func (c *compiler) loopTries() int {
+	tries, loops := c.current.tries, len(c.current.loops)
+	n := len(tries)
+	for n > 0 && tries[n-1].loops >= loops {
+		n--
+	}
+	return n
+}
+
+// leaveTries ends the handlers of the try expressions of the current
+// function from the nth on and runs their finally blocks, innermost first,
+// for a jump out of them. A finally block is compiled outside of its own
+// try, so a return in it does not run it again.
+func (c *compiler) leaveTries(n int) {
+	tries := c.current.tries
+	for i := len(tries) - 1; i >= n; i-- {
+		for j := 0; j < tries[i].handlers; j++ {
+			c.emit(OpEndTry)
+		}
+		if tries[i].finally != nil {
+			c.current.tries = tries[:i]
+			c.block(tries[i].finally)
+			c.emit(OpPop)
+		}
+	}
+	c.current.tries = tries
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/danecwalker/ponic/engine/internal/testutil"
+	"github.com/danecwalker/ponic/engine/resolver"
+)
+
+func compile(t *testing.T, src string) (*Program, error) {
+	t.Helper()
+	program := testutil.Parse(t, src)
+	noGlobals := func(string) (bool, bool) { return false, false }
+	if errs := resolver.Resolve(program, noGlobals); len(errs) > 0 {
+		t.Fatalf("unexpected error: %s", errs[0])
+	}
+	return Compile(program, NewGlobals())
+}
+
+func TestLimits(t *testing.T) {
+	// each `a;` compiles to 3 bytes, so 22000 of them make a function body
+	// longer than a 16-bit jump can cross
+	long := strings.Repeat("a; ", 22000)
+	var lets strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&lets, "let v%c%c = %d; ", 'a'+i/26, 'a'+i%26, i)
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "jump over a long if body",
+			src:  "fn f(a) { if (a) { " + long + "} }",
+			want: "0:0: function f is too long: its code exceeds 65535 bytes",
+		},
+		{
+			name: "jump back over a long loop body",
+			src:  "fn f(a) { for (a) { " + long + "} }",
+			want: "0:0: function f is too long: its code exceeds 65535 bytes",
+		},
+		{
+			name: "long top-level code",
+			src:  "let a = true; if (a) { " + long + "}",
+			want: "0:0: function main is too long: its code exceeds 65535 bytes",
+		},
+		{
+			name: "too many constants",
+			src:  strings.Repeat("1; ", 1<<16+1),
+			want: "0:0: too many constants",
+		},
+		{
+			name: "too many array elements",
+			src:  "fn f(a) { return [" + strings.Repeat("a, ", 1<<16) + "a]; }",
+			want: "0:0: function f is too large: operand 65537 of OpArray exceeds 65535",
+		},
+		{
+			name: "too many variables",
+			src:  "fn f() { " + lets.String() + "}",
+			want: "0:0: function f has more than 255 variables",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compile(t, tt.src)
+			if err == nil {
+				t.Fatalf("compiled without error, want %q", tt.want)
+			}
+			if _, ok := err.(*Error); !ok {
+				t.Fatalf("got %T, want *compiler.Error", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got error %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestJumpTargetsBelowLimit(t *testing.T) {
+	// just under the limit every jump target still fits
+	prog, err := compile(t, "fn f(a) { if (a) { "+strings.Repeat("a; ", 21000)+"} }")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := prog.Constants[len(prog.Constants)-1].(*Function)
+	if n := len(f.Instructions); n > 1<<16-1 {
+		t.Fatalf("test function is %d bytes long, want it below the limit", n)
+	}
+}
+
+func TestUnresolved(t *testing.T) {
+	_, err := Compile(testutil.Parse(t, "let x = 1;\nx"), NewGlobals())
+	if err == nil {
+		t.Fatal("compiled a tree the resolver has not checked")
+	}
+	if want := "1:5: x was not resolved"; err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package compiler
+
+type symbolKind int
+
+const (
+	globalSymbol symbolKind = iota
+	localSymbol
+	freeSymbol
+	// nameSymbol is a name with no declaration in the module, looked up
+	// among the globals of the embedding program and the builtins when it
+	// is run.
+	nameSymbol
+)
+
+type symbol struct {
+	name     string
+	kind     symbolKind
+	index    int
+	constant bool
+}
+
+// Globals is the symbol table of the top level of a module. It outlives a
+// single compilation, so code compiled later for the same module, such as
+// the next input of a REPL, sees the bindings of code compiled earlier.
+type Globals struct {
+	// Names holds the name of each global slot.
+	Names []string
+	// Constant tells whether each global slot was declared with const.
+	Constant []bool
+
+	symbols map[string]*symbol
+}
+
+func NewGlobals() *Globals {
+	return &Globals{symbols: make(map[string]*symbol)}
+}
+
+// Slot returns the slot of the global called name.
+func (g *Globals) Slot(name string) (int, bool) {
+	sym, ok := g.symbols[name]
+	if !ok {
+		return 0, false
+	}
+	return sym.index, true
+}
+
+func (g *Globals) declare(name string) *symbol {
+	if sym, ok := g.symbols[name]; ok {
+		return sym
+	}
+
+	sym := &symbol{name: name, kind: globalSymbol, index: len(g.Names)}
+	g.symbols[name] = sym
+	g.Names = append(g.Names, name)
+	g.Constant = append(g.Constant, false)
+	return sym
+}
+
+// funcScope tracks the variables of the function being compiled. Each
+// block of the function pushes a map of the names it declares; every
+// declaration gets a slot of its own in the frame of the function.
+type funcScope struct {
+	outer  *funcScope
+	blocks []map[string]*symbol
+
+	numLocals int
+	// free lists the variables of enclosing functions the function
+	// captures, by the symbol they resolve to in the enclosing function
+	free    []*symbol
+	freeMap map[*symbol]*symbol
+}
+
+func newFuncScope(outer *funcScope) *funcScope {
+	return &funcScope{outer: outer, freeMap: make(map[*symbol]*symbol)}
+}
+
+func (s *funcScope) enterBlock() {
+	s.blocks = append(s.blocks, make(map[string]*symbol))
+}
+
+func (s *funcScope) leaveBlock() {
+	s.blocks = s.blocks[:len(s.blocks)-1]
+}
+
+// declared returns the symbol name is declared as in the innermost block.
+func (s *funcScope) declared(name string) (*symbol, bool) {
+	sym, ok := s.blocks[len(s.blocks)-1][name]
+	return sym, ok
+}
+
+func (s *funcScope) defineLocal(name string, constant bool) *symbol {
+	sym := &symbol{name: name, kind: localSymbol, index: s.numLocals, constant: constant}
+	s.blocks[len(s.blocks)-1][name] = sym
+	s.numLocals++
+	return sym
+}
+
+// resolve finds the variable name refers to in the function, capturing it
+// if it belongs to an enclosing function. It reports false if name is not
+// a variable of this or any enclosing function.
+func (s *funcScope) resolve(name string) (*symbol, bool) {
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if sym, ok := s.blocks[i][name]; ok {
+			return sym, true
+		}
+	}
+	if s.outer == nil {
+		return nil, false
+	}
+
+	outer, ok := s.outer.resolve(name)
+	if !ok {
+		return nil, false
+	}
+	if sym, ok := s.freeMap[outer]; ok {
+		return sym, true
+	}
+
+	sym := &symbol{name: name, kind: freeSymbol, index: len(s.free), constant: outer.constant}
+	s.free = append(s.free, outer)
+	s.freeMap[outer] = sym
+	return sym, true
+}
@@ -0,0 +1,27 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package testutil holds helpers shared by the tests of the engine.
+package testutil
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/lexer"
+	"github.com/danecwalker/ponic/engine/parser"
+)
+
+// Parse parses src, failing the test if it has a syntax error.
+func Parse(t testing.TB, src string) *ast.AST {
+	t.Helper()
+	program, diagnostics := parser.NewParser(lexer.NewLexer(bufio.NewReader(strings.NewReader(src)))).Parse()
+	if len(diagnostics) > 0 {
+		t.Fatalf("syntax error: %s", diagnostics[0])
+	}
+	return program
+}
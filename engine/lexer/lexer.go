@@ -28,16 +28,35 @@ type Lexer interface {
 type lexer struct {
 	input    *bufio.Reader
 	position Position
+	start    Position
+
+	emitComments bool
+}
+
+type Option func(*lexer)
+
+// WithComments makes the lexer return comments as COMMENT tokens instead of
+// skipping them, for tools that need to keep them.
+func WithComments() Option {
+	return func(l *lexer) {
+		l.emitComments = true
+	}
 }
 
-func NewLexer(input *bufio.Reader) Lexer {
-	return &lexer{
+func NewLexer(input *bufio.Reader, opts ...Option) Lexer {
+	l := &lexer{
 		input: input,
 		position: Position{
-			Line:   0,
-			Column: 0,
+			Line:   1,
+			Column: 1,
 		},
 	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
 }
 
 func (l *lexer) IsEOF() bool {
@@ -55,6 +74,16 @@ func (l *lexer) Peek() rune {
 	return rune(b[0])
 }
 
+// peekAt returns the byte n positions ahead of the next one without
+// consuming anything, or 0 if the input ends before then.
+func (l *lexer) peekAt(n int) rune {
+	b, err := l.input.Peek(n + 1)
+	if err != nil || len(b) <= n {
+		return 0
+	}
+	return rune(b[n])
+}
+
 func (l *lexer) Consume() rune {
 	r, _, err := l.input.ReadRune()
 	if err != nil {
@@ -63,7 +92,12 @@ func (l *lexer) Consume() rune {
 		}
 		panic(err)
 	}
-	l.position.Column++
+	if r == '\n' {
+		l.position.Line++
+		l.position.Column = 1
+	} else {
+		l.position.Column++
+	}
 	return r
 }
 
@@ -80,24 +114,15 @@ func (l *lexer) ConsumeWhile(predicate func(rune) bool) string {
 }
 
 func (l *lexer) ConsumeWhitespace() {
-	l.ConsumeWhile(func(ch rune) bool {
-		if unicode.IsSpace(ch) {
-			if ch == '\n' {
-				l.position.Line++
-				l.position.Column = 1
-			}
-			return true
-		} else {
-			return false
-		}
-	})
+	l.ConsumeWhile(unicode.IsSpace)
 }
 
 func (l *lexer) Next() *Token {
 	l.ConsumeWhitespace()
+	l.start = l.position
 	switch l.Peek() {
 	case 0:
-		return l.newToken(EOF, string(l.Consume()))
+		return l.newToken(EOF, "")
 	case '=':
 		lit := string(l.Consume())
 		if l.Peek() == '=' {
@@ -132,21 +157,38 @@ func (l *lexer) Next() *Token {
 			lit += string(l.Consume())
 			return l.newToken(ASTER_ASSIGN, lit)
 		}
-		return l.newToken(ASTER, string(l.Consume()))
+		return l.newToken(ASTER, lit)
 	case '%':
 		lit := string(l.Consume())
 		if l.Peek() == '=' {
 			lit += string(l.Consume())
 			return l.newToken(MOD_ASSIGN, lit)
 		}
-		return l.newToken(MOD, string(l.Consume()))
+		return l.newToken(MOD, lit)
 	case '/':
 		lit := string(l.Consume())
-		if l.Peek() == '=' {
+		switch l.Peek() {
+		case '=':
 			lit += string(l.Consume())
 			return l.newToken(SLASH_ASSIGN, lit)
+		case '/':
+			lit += l.ConsumeWhile(func(r rune) bool { return r != '\n' && r != 0 })
+			return l.comment(lit)
+		case '*':
+			lit += string(l.Consume())
+			for {
+				ch := l.Consume()
+				if ch == 0 {
+					return l.newToken(ILLEGAL, lit)
+				}
+				lit += string(ch)
+				if ch == '*' && l.Peek() == '/' {
+					lit += string(l.Consume())
+					return l.comment(lit)
+				}
+			}
 		}
-		return l.newToken(SLASH, string(l.Consume()))
+		return l.newToken(SLASH, lit)
 	case '<':
 		lit := string(l.Consume())
 		if l.Peek() == '=' {
@@ -161,10 +203,28 @@ func (l *lexer) Next() *Token {
 			return l.newToken(GT_EQ, lit)
 		}
 		return l.newToken(GT, lit)
+	case '&':
+		lit := string(l.Consume())
+		if l.Peek() == '&' {
+			lit += string(l.Consume())
+			return l.newToken(AND, lit)
+		}
+		return l.newToken(ILLEGAL, lit)
+	case '|':
+		lit := string(l.Consume())
+		if l.Peek() == '|' {
+			lit += string(l.Consume())
+			return l.newToken(OR, lit)
+		}
+		return l.newToken(ILLEGAL, lit)
 	case ',':
 		return l.newToken(COMMA, string(l.Consume()))
 	case ';':
 		return l.newToken(SEMICOLON, string(l.Consume()))
+	case ':':
+		return l.newToken(COLON, string(l.Consume()))
+	case '.':
+		return l.newToken(DOT, string(l.Consume()))
 	case '(':
 		return l.newToken(LPAREN, string(l.Consume()))
 	case ')':
@@ -173,24 +233,65 @@ func (l *lexer) Next() *Token {
 		return l.newToken(LBRACE, string(l.Consume()))
 	case '}':
 		return l.newToken(RBRACE, string(l.Consume()))
+	case '[':
+		return l.newToken(LBRACKET, string(l.Consume()))
+	case ']':
+		return l.newToken(RBRACKET, string(l.Consume()))
 	case '"':
 		l.Consume()
-		lit := l.ConsumeWhile(func(r rune) bool { return r != '"' })
-		l.Consume()
+		lit := l.ConsumeWhile(func(r rune) bool { return r != '"' && r != 0 })
+		if l.Consume() == 0 {
+			return l.newToken(ILLEGAL, `"`+lit)
+		}
 		return l.newToken(STRING, lit)
 	default:
 		if unicode.IsLetter(l.Peek()) {
 			lit := l.ConsumeWhile(unicode.IsLetter)
 			return l.newToken(lookupIdent(lit), lit)
 		} else if unicode.IsDigit(l.Peek()) {
-			lit := l.ConsumeWhile(unicode.IsDigit)
-			return l.newToken(INT, lit)
+			return l.number()
 		} else {
 			return l.newToken(ILLEGAL, string(l.Consume()))
 		}
 	}
 }
 
+// number lexes an INT, or a FLOAT when the digits are followed by a
+// fraction (`3.14`), an exponent (`1e-9`) or both.
+func (l *lexer) number() *Token {
+	tokenType := INT
+	lit := l.ConsumeWhile(unicode.IsDigit)
+
+	if l.Peek() == '.' && unicode.IsDigit(l.peekAt(1)) {
+		tokenType = FLOAT
+		lit += string(l.Consume())
+		lit += l.ConsumeWhile(unicode.IsDigit)
+	}
+
+	if ch := l.Peek(); ch == 'e' || ch == 'E' {
+		sign := l.peekAt(1)
+		if unicode.IsDigit(sign) || ((sign == '+' || sign == '-') && unicode.IsDigit(l.peekAt(2))) {
+			tokenType = FLOAT
+			lit += string(l.Consume())
+			if !unicode.IsDigit(l.Peek()) {
+				lit += string(l.Consume())
+			}
+			lit += l.ConsumeWhile(unicode.IsDigit)
+		}
+	}
+
+	return l.newToken(tokenType, lit)
+}
+
+// comment returns the comment just consumed as a token, or skips it and
+// returns the token that follows.
+func (l *lexer) comment(lit string) *Token {
+	if l.emitComments {
+		return l.newToken(COMMENT, lit)
+	}
+	return l.Next()
+}
+
 func lookupIdent(ident string) TokenType {
 	keyword, ok := keywords[ident]
 	if ok {
@@ -0,0 +1,153 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package lexer
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// lex returns the tokens of src up to EOF, each as type, literal and the
+// positions it starts and ends at.
+func lex(src string, opts ...Option) []string {
+	l := NewLexer(bufio.NewReader(strings.NewReader(src)), opts...)
+	var tokens []string
+	for {
+		tok := l.Next()
+		if tok.Type == EOF {
+			return tokens
+		}
+		tokens = append(tokens, fmt.Sprintf("%s %q %d:%d-%d:%d", tok.Type, tok.Literal, tok.Pos.Line, tok.Pos.Column, tok.End.Line, tok.End.Column))
+	}
+}
+
+func TestLexer(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		opts []Option
+		want []string
+	}{
+		{
+			name: "positions start at line 1 column 1",
+			src:  "let x = 10;\n  x += 1",
+			want: []string{
+				`LET "let" 1:1-1:4`,
+				`IDENT "x" 1:5-1:6`,
+				`= "=" 1:7-1:8`,
+				`INT "10" 1:9-1:11`,
+				`; ";" 1:11-1:12`,
+				`IDENT "x" 2:3-2:4`,
+				`+= "+=" 2:5-2:7`,
+				`INT "1" 2:8-2:9`,
+			},
+		},
+		{
+			name: "line comments are skipped",
+			src:  "a // the rest\nb / c",
+			want: []string{
+				`IDENT "a" 1:1-1:2`,
+				`IDENT "b" 2:1-2:2`,
+				`/ "/" 2:3-2:4`,
+				`IDENT "c" 2:5-2:6`,
+			},
+		},
+		{
+			name: "block comments are skipped",
+			src:  "a /* one\ntwo */ b",
+			want: []string{
+				`IDENT "a" 1:1-1:2`,
+				`IDENT "b" 2:8-2:9`,
+			},
+		},
+		{
+			name: "comments as tokens",
+			src:  "a // line\n/* block */ b",
+			opts: []Option{WithComments()},
+			want: []string{
+				`IDENT "a" 1:1-1:2`,
+				`COMMENT "// line" 1:3-1:10`,
+				`COMMENT "/* block */" 2:1-2:12`,
+				`IDENT "b" 2:13-2:14`,
+			},
+		},
+		{
+			name: "line comment at the end of the input",
+			src:  "a // no newline",
+			opts: []Option{WithComments()},
+			want: []string{
+				`IDENT "a" 1:1-1:2`,
+				`COMMENT "// no newline" 1:3-1:16`,
+			},
+		},
+		{
+			name: "numbers",
+			src:  "7 1.5 1e3 2.5E-3 4e+2",
+			want: []string{
+				`INT "7" 1:1-1:2`,
+				`FLOAT "1.5" 1:3-1:6`,
+				`FLOAT "1e3" 1:7-1:10`,
+				`FLOAT "2.5E-3" 1:11-1:17`,
+				`FLOAT "4e+2" 1:18-1:22`,
+			},
+		},
+		{
+			name: "a dot without digits on both sides is not part of a number",
+			src:  "1. .5",
+			want: []string{
+				`INT "1" 1:1-1:2`,
+				`. "." 1:2-1:3`,
+				`. "." 1:4-1:5`,
+				`INT "5" 1:5-1:6`,
+			},
+		},
+		{
+			name: "an exponent needs digits",
+			src:  "2e x",
+			want: []string{
+				`INT "2" 1:1-1:2`,
+				`IDENT "e" 1:2-1:3`,
+				`IDENT "x" 1:4-1:5`,
+			},
+		},
+		{
+			name: "strings",
+			src:  `"a b" ""`,
+			want: []string{
+				`STRING "a b" 1:1-1:6`,
+				`STRING "" 1:7-1:9`,
+			},
+		},
+		{
+			name: "unterminated string",
+			src:  `print("abc)`,
+			want: []string{
+				`IDENT "print" 1:1-1:6`,
+				`( "(" 1:6-1:7`,
+				`ILLEGAL "\"abc)" 1:7-1:12`,
+			},
+		},
+		{
+			name: "unterminated block comment",
+			src:  "a /* never closed",
+			want: []string{
+				`IDENT "a" 1:1-1:2`,
+				`ILLEGAL "/* never closed" 1:3-1:18`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lex(tt.src, tt.opts...)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got tokens\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ type Token struct {
 	Type    TokenType
 	Literal string
 	Pos     Position
+	End     Position
 }
 
 func (t Token) String() string {
@@ -21,7 +22,8 @@ func (l *lexer) newToken(tokenType TokenType, lit string) *Token {
 	return &Token{
 		Type:    tokenType,
 		Literal: lit,
-		Pos:     l.position,
+		Pos:     l.start,
+		End:     l.position,
 	}
 }
 
@@ -31,10 +33,12 @@ const (
 	// Special tokens
 	ILLEGAL TokenType = iota
 	EOF
+	COMMENT // only emitted when the lexer is created WithComments
 
 	// Identifiers + literals
 	IDENT  // main
 	INT    // 12345
+	FLOAT  // 3.14
 	STRING // "hello world"
 
 	// Operators
@@ -59,15 +63,23 @@ const (
 	LT_EQ  // <=
 	GT_EQ  // >=
 
+	AND // &&
+	OR  // ||
+
 	// Delimiters
 	COMMA     // ,
 	SEMICOLON // ;
+	COLON     // :
+	DOT       // .
 
 	LPAREN // (
 	RPAREN // )
 	LBRACE // {
 	RBRACE // }
 
+	LBRACKET // [
+	RBRACKET // ]
+
 	// Keywords
 	FUNCTION // fn
 	LET      // let
@@ -78,14 +90,28 @@ const (
 	ELSE     // else
 	FOR      // for
 	RETURN   // return
+	TRY      // try
+	CATCH    // catch
+	FINALLY  // finally
+	THROW    // throw
+	BREAK    // break
+	CONTINUE // continue
+	IN       // in
+	YIELD    // yield
+	STRUCT   // struct
+	IMPORT   // import
+	EXPORT   // export
+	AS       // as
 )
 
 var TokenMap = [...]string{
 	ILLEGAL: "ILLEGAL",
 	EOF:     "EOF",
+	COMMENT: "COMMENT",
 
 	IDENT:  "IDENT",
 	INT:    "INT",
+	FLOAT:  "FLOAT",
 	STRING: "STRING",
 
 	ASSIGN:       "=",
@@ -109,14 +135,22 @@ var TokenMap = [...]string{
 	LT_EQ:  "<=",
 	GT_EQ:  ">=",
 
+	AND: "&&",
+	OR:  "||",
+
 	COMMA:     ",",
 	SEMICOLON: ";",
+	COLON:     ":",
+	DOT:       ".",
 
 	LPAREN: "(",
 	RPAREN: ")",
 	LBRACE: "{",
 	RBRACE: "}",
 
+	LBRACKET: "[",
+	RBRACKET: "]",
+
 	FUNCTION: "FUNCTION",
 	LET:      "LET",
 	CONST:    "CONST",
@@ -126,6 +160,18 @@ var TokenMap = [...]string{
 	ELSE:     "ELSE",
 	FOR:      "FOR",
 	RETURN:   "RETURN",
+	TRY:      "TRY",
+	CATCH:    "CATCH",
+	FINALLY:  "FINALLY",
+	THROW:    "THROW",
+	BREAK:    "BREAK",
+	CONTINUE: "CONTINUE",
+	IN:       "IN",
+	YIELD:    "YIELD",
+	STRUCT:   "STRUCT",
+	IMPORT:   "IMPORT",
+	EXPORT:   "EXPORT",
+	AS:       "AS",
 }
 
 func (t TokenType) String() string {
@@ -133,13 +179,25 @@ func (t TokenType) String() string {
 }
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"const":  CONST,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"for":    FOR,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"for":      FOR,
+	"return":   RETURN,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"in":       IN,
+	"yield":    YIELD,
+	"struct":   STRUCT,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"as":       AS,
 }
@@ -0,0 +1,99 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package object
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/danecwalker/ponic/engine/lexer"
+)
+
+// Frame is one entry of a Ponic call stack: the function that was called
+// and where it was called from.
+type Frame struct {
+	Function string
+	File     string
+	Pos      lexer.Position
+}
+
+// Error is a runtime error. It is returned as a value from the runtime and
+// unwinds every enclosing block and call, collecting a Frame for each call
+// it passes through, until a try expression catches it. Stack is ordered
+// innermost call first. Value holds the operand of a throw statement and is
+// nil for errors raised by the runtime itself. File is the path of the
+// module Pos is in, if known.
+type Error struct {
+	Message string
+	File    string
+	Pos     lexer.Position
+	Stack   []Frame
+	Value   Object
+
+	// elided frames were dropped from Stack before its index elidedAt
+	elided, elidedAt int
+}
+
+func NewError(format string, args ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Type() Type {
+	return ERROR
+}
+func (e *Error) Inspect() string {
+	return e.Message
+}
+func (e *Error) String() string {
+	return fmt.Sprintf("Error(%s)", e.Message)
+}
+
+func (e *Error) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s%d:%d: %s", filePrefix(e.File), e.Pos.Line, e.Pos.Column, e.Message)
+	for i, frame := range e.Stack {
+		if e.elided > 0 && i == e.elidedAt {
+			fmt.Fprintf(&sb, "\n    ... %d more frames", e.elided)
+		}
+		fmt.Fprintf(&sb, "\n    at %s (%s%d:%d)", frame.Function, filePrefix(frame.File), frame.Pos.Line, frame.Pos.Column)
+	}
+	return sb.String()
+}
+
+func filePrefix(file string) string {
+	if file == "" {
+		return ""
+	}
+	return file + ":"
+}
+
+// At sets the position of the error if it does not have one yet.
+func (e *Error) At(pos lexer.Position) *Error {
+	if e.Pos.Line == 0 {
+		e.Pos = pos
+	}
+	return e
+}
+
+// In sets the file of the error if it does not have one yet.
+func (e *Error) In(file string) *Error {
+	if e.File == "" {
+		e.File = file
+	}
+	return e
+}
+
+// Elide drops all but the innermost and outermost keep frames of Stack, so
+// that a deep recursion does not print thousands of identical lines. The
+// error notes how many frames it dropped where they were.
+func (e *Error) Elide(keep int) *Error {
+	if n := len(e.Stack) - 2*keep; n > 0 {
+		e.Stack = append(e.Stack[:keep], e.Stack[len(e.Stack)-keep:]...)
+		e.elided += n
+		e.elidedAt = keep
+	}
+	return e
+}
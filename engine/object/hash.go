@@ -0,0 +1,107 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HashKey identifies a Hashable value inside a Hash. Two values have the
+// same HashKey exactly when they are equal and of the same type.
+type HashKey struct {
+	Type Type
+	Int  int64
+	Str  string
+}
+
+// Hashable is implemented by the objects that can be used as hash keys.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: STRING, Str: s.Value}
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: INTEGER, Int: i.Value}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	if b.Value {
+		return HashKey{Type: BOOLEAN, Int: 1}
+	}
+	return HashKey{Type: BOOLEAN, Int: 0}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash is a map from Hashable keys to values that remembers the order in
+// which keys were first inserted and iterates in that order.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Order []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+func (h *Hash) Set(key Hashable, val Object) {
+	hk := key.HashKey()
+	if _, ok := h.Pairs[hk]; !ok {
+		h.Order = append(h.Order, hk)
+	}
+	h.Pairs[hk] = HashPair{Key: key, Value: val}
+}
+
+func (h *Hash) Delete(key Hashable) bool {
+	hk := key.HashKey()
+	if _, ok := h.Pairs[hk]; !ok {
+		return false
+	}
+	delete(h.Pairs, hk)
+	for i, k := range h.Order {
+		if k == hk {
+			h.Order = append(h.Order[:i], h.Order[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Entries returns the pairs of the hash in insertion order.
+func (h *Hash) Entries() []HashPair {
+	entries := make([]HashPair, len(h.Order))
+	for i, hk := range h.Order {
+		entries[i] = h.Pairs[hk]
+	}
+	return entries
+}
+
+func (h *Hash) Type() Type {
+	return HASH
+}
+func (h *Hash) Inspect() string {
+	pairs := make([]string, len(h.Order))
+	for i, pair := range h.Entries() {
+		pairs[i] = fmt.Sprintf("%s: %s", inspectElement(pair.Key), inspectElement(pair.Value))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+func (h *Hash) String() string {
+	return fmt.Sprintf("Hash(%s)", h.Inspect())
+}
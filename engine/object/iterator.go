@@ -0,0 +1,92 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package object
+
+// Iterator is a lazy sequence of values. Next returns the next value and
+// true, or false once the sequence is exhausted. An *Error returned by Next
+// ends the iteration with that error.
+type Iterator interface {
+	Object
+	Next() (Object, bool)
+}
+
+// Closer is implemented by iterators that hold on to resources until they
+// are exhausted. Close releases them when iteration stops early.
+type Closer interface {
+	Close()
+}
+
+// Iterable is implemented by the collections that can produce an Iterator
+// over their elements. Iterating a Hash produces its keys.
+type Iterable interface {
+	Object
+	Iter() Iterator
+}
+
+func (a *Array) Iter() Iterator {
+	i := 0
+	return &FuncIterator{Func: func() (Object, bool) {
+		if i >= len(a.Elements) {
+			return nil, false
+		}
+		i++
+		return a.Elements[i-1], true
+	}}
+}
+
+func (h *Hash) Iter() Iterator {
+	entries := h.Entries()
+	i := 0
+	return &FuncIterator{Func: func() (Object, bool) {
+		if i >= len(entries) {
+			return nil, false
+		}
+		i++
+		return entries[i-1].Key, true
+	}}
+}
+
+func (s *String) Iter() Iterator {
+	chars := []rune(s.Value)
+	i := 0
+	return &FuncIterator{Func: func() (Object, bool) {
+		if i >= len(chars) {
+			return nil, false
+		}
+		i++
+		return &String{Value: string(chars[i-1])}, true
+	}}
+}
+
+func (r *Range) Iter() Iterator {
+	current := r.Start
+	return &FuncIterator{Func: func() (Object, bool) {
+		if (r.Step > 0 && current >= r.End) || (r.Step < 0 && current <= r.End) {
+			return nil, false
+		}
+		current += r.Step
+		return &Integer{Value: current - r.Step}, true
+	}}
+}
+
+// FuncIterator is an Iterator backed by a Go function.
+type FuncIterator struct {
+	Func func() (Object, bool)
+}
+
+func (fi *FuncIterator) Next() (Object, bool) {
+	return fi.Func()
+}
+
+func (fi *FuncIterator) Type() Type {
+	return ITERATOR
+}
+func (fi *FuncIterator) Inspect() string {
+	return "iterator"
+}
+func (fi *FuncIterator) String() string {
+	return "Iterator()"
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package object
+
+// Importer loads the module at path, which is relative to the module that
+// imports it.
+type Importer interface {
+	Import(path string, from *Module) (*Module, *Error)
+}
+
+// Module is a loaded .pc file. Its exported bindings are read through
+// member access on the module value.
+type Module struct {
+	Name     string
+	Path     string
+	Scope    *Scope
+	Exports  map[string]bool
+	Importer Importer
+	// Lookup, if set, finds the top-level bindings of the module for an
+	// engine that does not keep them in Scope.
+	Lookup func(name string) (Object, bool)
+}
+
+func (m *Module) Type() Type {
+	return MODULE
+}
+func (m *Module) Inspect() string {
+	return "module " + m.Name
+}
+func (m *Module) String() string {
+	return "Module(" + m.Path + ")"
+}
+
+// Get returns the value of name at the top level of the module, or in a
+// scope enclosing it.
+func (m *Module) Get(name string) (Object, bool) {
+	if m.Lookup != nil {
+		if val, ok := m.Lookup(name); ok {
+			return val, true
+		}
+	}
+	return m.Scope.Get(name)
+}
+
+// Export returns the value of the exported binding name.
+func (m *Module) Export(name string) (Object, bool) {
+	if !m.Exports[name] {
+		return nil, false
+	}
+	return m.Get(name)
+}
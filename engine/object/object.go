@@ -7,6 +7,8 @@ package object
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/danecwalker/ponic/engine/ast"
 )
@@ -25,6 +27,15 @@ const (
 	NULL
 	STRING
 	FUNCTION
+	ERROR
+	FLOAT
+	ARRAY
+	HASH
+	RANGE
+	ITERATOR
+	STRUCT
+	INSTANCE
+	MODULE
 )
 
 type ReturnValue struct {
@@ -43,8 +54,39 @@ func (rv *ReturnValue) String() string {
 	return rv.Value.String()
 }
 
+// Break and Continue are the signals produced by break and continue
+// statements. Like ReturnValue they stop every enclosing block until they
+// reach the for loop that handles them.
+type Break struct{}
+
+func (b *Break) Type() Type {
+	return NULL
+}
+func (b *Break) Inspect() string {
+	return "break"
+}
+func (b *Break) String() string {
+	return "Break()"
+}
+
+type Continue struct{}
+
+func (c *Continue) Type() Type {
+	return NULL
+}
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+func (c *Continue) String() string {
+	return "Continue()"
+}
+
+// BuiltinFunction is a function implemented in Go. It reports failure by
+// returning an *Error.
+type BuiltinFunction func(args ...Object) Object
+
 type Builtin struct {
-	Func func(args ...Object) Object
+	Func BuiltinFunction
 }
 
 func (b *Builtin) Type() Type {
@@ -71,6 +113,24 @@ func (i *Integer) String() string {
 	return fmt.Sprintf("Int(%d)", i.Value)
 }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() Type {
+	return FLOAT
+}
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+func (f *Float) String() string {
+	return fmt.Sprintf("Float(%s)", f.Inspect())
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -115,6 +175,7 @@ type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Scope      *Scope
+	Generator  bool
 }
 
 func (f *Function) Type() Type {
@@ -126,3 +187,103 @@ func (f *Function) Inspect() string {
 func (f *Function) String() string {
 	return "Function()"
 }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() Type {
+	return ARRAY
+}
+func (a *Array) Inspect() string {
+	elements := make([]string, len(a.Elements))
+	for i, e := range a.Elements {
+		elements[i] = inspectElement(e)
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+func (a *Array) String() string {
+	return fmt.Sprintf("Array(%s)", a.Elements)
+}
+
+// inspectElement is Inspect for values nested in a collection, where
+// strings are quoted so that ["a, b"] and ["a", "b"] print differently.
+func inspectElement(obj Object) string {
+	if s, ok := obj.(*String); ok {
+		return "\"" + s.Value + "\""
+	}
+	return obj.Inspect()
+}
+
+// Range is the lazy sequence of integers from Start up to, but not
+// including, End in increments of Step. Step is never zero.
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+func (r *Range) Type() Type {
+	return RANGE
+}
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+func (r *Range) String() string {
+	return fmt.Sprintf("Range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// StructType is the value bound to the name of a struct declaration. Calling
+// it constructs an Instance from one argument per field. Its methods are
+// functions of the engine that declared them.
+type StructType struct {
+	Name    string
+	Fields  []string
+	Methods map[string]Object
+}
+
+func (st *StructType) Type() Type {
+	return STRUCT
+}
+func (st *StructType) Inspect() string {
+	return "struct " + st.Name
+}
+func (st *StructType) String() string {
+	return fmt.Sprintf("StructType(%s, %s)", st.Name, st.Fields)
+}
+
+type Instance struct {
+	Struct *StructType
+	Fields map[string]Object
+}
+
+func (i *Instance) Type() Type {
+	return INSTANCE
+}
+func (i *Instance) Inspect() string {
+	fields := make([]string, len(i.Struct.Fields))
+	for n, name := range i.Struct.Fields {
+		fields[n] = fmt.Sprintf("%s: %s", name, inspectElement(i.Fields[name]))
+	}
+	return i.Struct.Name + "{" + strings.Join(fields, ", ") + "}"
+}
+func (i *Instance) String() string {
+	return fmt.Sprintf("Instance(%s)", i.Inspect())
+}
+
+// BoundMethod is a method looked up on an instance. Calling it passes the
+// instance as the first argument, the method's self parameter.
+type BoundMethod struct {
+	Receiver Object
+	Method   Object
+}
+
+func (bm *BoundMethod) Type() Type {
+	return FUNCTION
+}
+func (bm *BoundMethod) Inspect() string {
+	return "method"
+}
+func (bm *BoundMethod) String() string {
+	return "BoundMethod()"
+}
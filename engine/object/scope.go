@@ -18,9 +18,15 @@ type ValueBinding struct {
 	Type   BindType
 }
 
+// Scope holds variables by name, for the top level of a module, and in
+// numbered slots, for the locals of functions and blocks whose slots were
+// assigned by the resolver.
 type Scope struct {
 	Parent *Scope
 	Values map[string]ValueBinding
+	Slots  []ValueBinding
+	// Module is set on the top-level scope of a module.
+	Module *Module
 }
 
 func NewScope() *Scope {
@@ -29,6 +35,12 @@ func NewScope() *Scope {
 	}
 }
 
+// NewEnclosedScope returns a scope for locals nested in parent. Its map is
+// only allocated once a value is bound by name.
+func NewEnclosedScope(parent *Scope) *Scope {
+	return &Scope{Parent: parent}
+}
+
 func (s *Scope) Get(name string) (Object, bool) {
 	bind, ok := s.Values[name]
 	if !ok && s.Parent != nil {
@@ -37,15 +49,100 @@ func (s *Scope) Get(name string) (Object, bool) {
 	return bind.Object, ok
 }
 
-func (s *Scope) Set(name string, val Object, bindType BindType) {
+// CurrentModule returns the module the scope belongs to, or nil for a scope
+// that is not part of any module.
+func (s *Scope) CurrentModule() *Module {
+	for ; s != nil; s = s.Parent {
+		if s.Module != nil {
+			return s.Module
+		}
+	}
+	return nil
+}
+
+func (s *Scope) Set(name string, val Object, bindType BindType) *Error {
 	bind, ok := s.Values[name]
 	if ok {
 		if bind.Type == CONST {
-			panic("Cannot reassign constant")
+			return NewError("Cannot reassign constant %s", name)
 		} else {
 			s.Values[name] = ValueBinding{val, bind.Type}
 		}
 	} else {
+		if s.Values == nil {
+			s.Values = make(map[string]ValueBinding)
+		}
 		s.Values[name] = ValueBinding{val, bindType}
 	}
+	return nil
+}
+
+// Assign replaces the value of name in the nearest scope that binds it,
+// starting from s. It is an error if no scope binds name or if it is bound
+// as a constant.
+func (s *Scope) Assign(name string, val Object) *Error {
+	for ; s != nil; s = s.Parent {
+		bind, ok := s.Values[name]
+		if !ok {
+			continue
+		}
+		if bind.Type == CONST {
+			return NewError("Cannot reassign constant %s", name)
+		}
+		s.Values[name] = ValueBinding{val, bind.Type}
+		return nil
+	}
+	return NewError("Undefined variable %s", name)
+}
+
+// Binding returns the binding of name in s or the nearest scope enclosing
+// it that has one.
+func (s *Scope) Binding(name string) (ValueBinding, bool) {
+	for ; s != nil; s = s.Parent {
+		if bind, ok := s.Values[name]; ok {
+			return bind, true
+		}
+	}
+	return ValueBinding{}, false
+}
+
+// GetSlot returns the value in slot of the scope depth levels out from s.
+// It reports false if nothing has been bound there yet.
+func (s *Scope) GetSlot(depth, slot int) (Object, bool) {
+	s = s.ancestor(depth)
+	if slot >= len(s.Slots) || s.Slots[slot].Object == nil {
+		return nil, false
+	}
+	return s.Slots[slot].Object, true
+}
+
+// SetSlot declares the variable in slot of s as val. The resolver makes
+// sure that each slot of a scope is declared once.
+func (s *Scope) SetSlot(slot int, val Object, bindType BindType) {
+	for len(s.Slots) <= slot {
+		s.Slots = append(s.Slots, ValueBinding{})
+	}
+	s.Slots[slot] = ValueBinding{val, bindType}
+}
+
+// AssignSlot replaces the value in slot of the scope depth levels out from
+// s, with the same rules as Assign. A slot holding no value has not been
+// declared yet.
+func (s *Scope) AssignSlot(depth, slot int, name string, val Object) *Error {
+	s = s.ancestor(depth)
+	if slot >= len(s.Slots) || s.Slots[slot].Object == nil {
+		return NewError("%s used before its declaration", name)
+	}
+	if s.Slots[slot].Type == CONST {
+		return NewError("Cannot reassign constant %s", name)
+	}
+	s.Slots[slot].Object = val
+	return nil
+}
+
+func (s *Scope) ancestor(depth int) *Scope {
+	for ; depth > 0; depth-- {
+		s = s.Parent
+	}
+	return s
 }
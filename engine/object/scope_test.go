@@ -0,0 +1,54 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package object
+
+import "testing"
+
+func TestAssign(t *testing.T) {
+	outer := NewScope()
+	outer.Set("x", &Integer{Value: 1}, LET)
+	outer.Set("limit", &Integer{Value: 3}, CONST)
+	inner := NewEnclosedScope(outer)
+
+	if err := inner.Assign("x", &Integer{Value: 2}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Message)
+	}
+	if _, ok := inner.Values["x"]; ok {
+		t.Errorf("Assign bound x in the inner scope")
+	}
+	if got, _ := outer.Get("x"); got.Inspect() != "2" {
+		t.Errorf("got x = %s in the outer scope, want 2", got.Inspect())
+	}
+
+	if err := inner.Assign("limit", &Integer{Value: 4}); err == nil || err.Message != "Cannot reassign constant limit" {
+		t.Errorf("got %v, want an error reassigning limit", err)
+	}
+	if err := inner.Assign("missing", &Integer{Value: 4}); err == nil || err.Message != "Undefined variable missing" {
+		t.Errorf("got %v, want an error for the undefined missing", err)
+	}
+}
+
+func TestAssignSlot(t *testing.T) {
+	outer := NewEnclosedScope(nil)
+	outer.SetSlot(0, &Integer{Value: 1}, LET)
+	outer.SetSlot(1, &Integer{Value: 3}, CONST)
+	inner := NewEnclosedScope(outer)
+
+	if err := inner.AssignSlot(1, 0, "x", &Integer{Value: 2}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Message)
+	}
+	if got, _ := inner.GetSlot(1, 0); got.Inspect() != "2" {
+		t.Errorf("got x = %s, want 2", got.Inspect())
+	}
+
+	if err := inner.AssignSlot(1, 1, "limit", &Integer{Value: 4}); err == nil || err.Message != "Cannot reassign constant limit" {
+		t.Errorf("got %v, want an error reassigning limit", err)
+	}
+	// a slot whose declaration has not run yet
+	if err := inner.AssignSlot(1, 2, "later", &Integer{Value: 4}); err == nil || err.Message != "later used before its declaration" {
+		t.Errorf("got %v, want an error for the undeclared later", err)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/danecwalker/ponic/engine/lexer"
+)
+
+// Diagnostic describes a syntax error found while parsing. Expected is
+// ILLEGAL when the parser was not looking for one particular token.
+type Diagnostic struct {
+	Message  string
+	Start    lexer.Position
+	End      lexer.Position
+	Expected lexer.TokenType
+	Found    lexer.TokenType
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Start.Line, d.Start.Column, d.Message)
+}
+
+func (d Diagnostic) Error() string {
+	return d.String()
+}
+
+func describe(t *lexer.Token) string {
+	switch t.Type {
+	case lexer.EOF:
+		return "end of file"
+	case lexer.STRING:
+		return fmt.Sprintf("string \"%s\"", t.Literal)
+	case lexer.ILLEGAL:
+		// the lexer ends a string or block comment that is never closed
+		// with an ILLEGAL token running to the end of the input
+		if strings.HasPrefix(t.Literal, `"`) {
+			return "unterminated string"
+		}
+		if strings.HasPrefix(t.Literal, "/*") {
+			return "unterminated comment"
+		}
+		return fmt.Sprintf("`%s`", t.Literal)
+	default:
+		return fmt.Sprintf("`%s`", t.Literal)
+	}
+}
+
+// errorAt records a diagnostic at t and puts the parser into panic mode.
+// Further errors are suppressed until the next synchronize, so a single
+// mistake does not produce a cascade of follow-on diagnostics.
+func (p *parser) errorAt(t *lexer.Token, expected lexer.TokenType, format string, args ...interface{}) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	p.reportAt(t, expected, format, args...)
+}
+
+// reportAt records a diagnostic at t without entering panic mode, for errors
+// in code that is otherwise well formed, so parsing carries on as normal.
+func (p *parser) reportAt(t *lexer.Token, expected lexer.TokenType, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Message:  fmt.Sprintf(format, args...),
+		Start:    t.Pos,
+		End:      t.End,
+		Expected: expected,
+		Found:    t.Type,
+	})
+}
+
+// expect consumes the next token if it is of type t. Otherwise it records a
+// diagnostic and leaves the token stream untouched.
+func (p *parser) expect(t lexer.TokenType) bool {
+	if p.isNext(t) {
+		p.eat()
+		return true
+	}
+
+	p.errorAt(p.next(), t, "expected `%s`, found %s", t, describe(p.next()))
+	return false
+}
+
+// synchronize leaves panic mode by skipping tokens up to the next statement
+// boundary: past a `;`, or up to a `}` or a token that begins a statement.
+// Blocks opened by the skipped tokens are skipped whole, so a `;` or `}`
+// inside them is no boundary. At the top level an unmatched `}` cannot close
+// anything and is skipped too, rather than left to be reported again. start
+// is the token the failed statement began at; at least one token is always
+// skipped past it so parsing is guaranteed to make progress.
+func (p *parser) synchronize(start *lexer.Token) {
+	p.panicking = false
+	open := 0
+	if p.next() == start && !p.isNext(lexer.EOF) {
+		if p.eat().Type == lexer.LBRACE {
+			open++
+		}
+	}
+
+	for !p.isNext(lexer.EOF) {
+		switch p.next().Type {
+		case lexer.LBRACE:
+			open++
+		case lexer.RBRACE:
+			if open > 0 {
+				open--
+				break
+			}
+			if p.blockDepth == 0 {
+				p.eat()
+			}
+			return
+		case lexer.SEMICOLON:
+			if open == 0 {
+				p.eat()
+				return
+			}
+		case lexer.LET, lexer.CONST, lexer.FUNCTION, lexer.RETURN:
+			if open == 0 {
+				return
+			}
+		}
+		p.eat()
+	}
+}
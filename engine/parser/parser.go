@@ -13,7 +13,7 @@ import (
 )
 
 type Parser interface {
-	Parse() *ast.AST
+	Parse() (*ast.AST, []Diagnostic)
 }
 
 type (
@@ -27,19 +27,33 @@ type parser struct {
 	peekToken *lexer.Token
 	nuds      map[lexer.TokenType]nud
 	leds      map[lexer.TokenType]led
+
+	diagnostics []Diagnostic
+	panicking   bool
+
+	// loopDepth counts the for loops enclosing the current position within
+	// the current function, for checking break and continue.
+	loopDepth int
+	// blockDepth counts the blocks enclosing the current position, for
+	// keeping import and export at the top level of a file.
+	blockDepth int
+	// function is the innermost function literal being parsed, which a
+	// yield statement turns into a generator.
+	function *ast.FunctionLiteral
 }
 
 func NewParser(l lexer.Lexer) Parser {
 	p := &parser{
-		lexer:     l,
-		curToken:  nil,
-		peekToken: l.Next(),
-		nuds:      make(map[lexer.TokenType]nud),
-		leds:      make(map[lexer.TokenType]led),
+		lexer:    l,
+		curToken: nil,
+		nuds:     make(map[lexer.TokenType]nud),
+		leds:     make(map[lexer.TokenType]led),
 	}
+	p.peekToken = p.nextToken()
 
 	p.registerNud(lexer.IDENT, p.parseIdentifier)
 	p.registerNud(lexer.INT, p.parseIntegerLiteral)
+	p.registerNud(lexer.FLOAT, p.parseFloatLiteral)
 	p.registerNud(lexer.TRUE, p.parseBooleanLiteral)
 	p.registerNud(lexer.FALSE, p.parseBooleanLiteral)
 	p.registerNud(lexer.STRING, p.parseStringLiteral)
@@ -47,12 +61,17 @@ func NewParser(l lexer.Lexer) Parser {
 	p.registerNud(lexer.FUNCTION, p.parseFunctionLiteral)
 	p.registerNud(lexer.IF, p.parseIfExpression)
 	p.registerNud(lexer.FOR, p.parseForExpression)
+	p.registerNud(lexer.TRY, p.parseTryExpression)
 
 	p.registerNud(lexer.MINUS, p.parsePrefixExpression)
 	p.registerNud(lexer.BANG, p.parsePrefixExpression)
 	p.registerNud(lexer.LPAREN, p.parseGroupedExpression)
+	p.registerNud(lexer.LBRACKET, p.parseArrayLiteral)
+	p.registerNud(lexer.LBRACE, p.parseHashLiteral)
 
 	p.registerLed(lexer.LPAREN, p.parseCallExpression)
+	p.registerLed(lexer.LBRACKET, p.parseIndexExpression)
+	p.registerLed(lexer.DOT, p.parseMemberExpression)
 
 	p.registerLed(lexer.ASSIGN, p.parseInfixExpression)
 	p.registerLed(lexer.PLUS_ASSIGN, p.parseInfixExpression)
@@ -72,6 +91,8 @@ func NewParser(l lexer.Lexer) Parser {
 	p.registerLed(lexer.NOT_EQ, p.parseInfixExpression)
 	p.registerLed(lexer.LT_EQ, p.parseInfixExpression)
 	p.registerLed(lexer.GT_EQ, p.parseInfixExpression)
+	p.registerLed(lexer.AND, p.parseInfixExpression)
+	p.registerLed(lexer.OR, p.parseInfixExpression)
 
 	return p
 }
@@ -90,34 +111,68 @@ func (p *parser) next() *lexer.Token {
 
 func (p *parser) eat() *lexer.Token {
 	p.curToken = p.peekToken
-	p.peekToken = p.lexer.Next()
+	p.peekToken = p.nextToken()
 	return p.curToken
 }
 
+// nextToken reads the next token from the lexer, skipping comments.
+func (p *parser) nextToken() *lexer.Token {
+	tok := p.lexer.Next()
+	for tok.Type == lexer.COMMENT {
+		tok = p.lexer.Next()
+	}
+	return tok
+}
+
 func (p *parser) isNext(t lexer.TokenType) bool {
 	return p.next().Type == t
 }
 
-func (p *parser) Parse() *ast.AST {
+func (p *parser) Parse() (*ast.AST, []Diagnostic) {
 	program := &ast.AST{}
 	program.Statements = []ast.Statement{}
 
 	for !p.isNext(lexer.EOF) {
+		start := p.next()
 		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize(start)
+			continue
+		}
 		program.Statements = append(program.Statements, stmt)
 	}
 
-	return program
+	return program, p.diagnostics
 }
 
+// parseStatement parses one statement. A `{` at the start of a statement
+// always opens a block; everywhere else parseExpression reads it as a hash
+// literal.
 func (p *parser) parseStatement() ast.Statement {
 	switch p.next().Type {
+	case lexer.LBRACE:
+		p.eat()
+		return p.parseBlockStatement()
 	case lexer.LET:
 		return p.parseLetStatement()
 	case lexer.CONST:
 		return p.parseConstStatement()
 	case lexer.RETURN:
 		return p.parseReturnStatement()
+	case lexer.THROW:
+		return p.parseThrowStatement()
+	case lexer.BREAK:
+		return p.parseBreakStatement()
+	case lexer.CONTINUE:
+		return p.parseContinueStatement()
+	case lexer.YIELD:
+		return p.parseYieldStatement()
+	case lexer.STRUCT:
+		return p.parseStructStatement()
+	case lexer.IMPORT:
+		return p.parseImportStatement()
+	case lexer.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -135,6 +190,150 @@ func (p *parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.eat()}
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.isNext(lexer.SEMICOLON) {
+		p.eat()
+	}
+
+	return stmt
+}
+
+func (p *parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.eat()}
+
+	if p.loopDepth == 0 {
+		p.reportAt(stmt.Token, lexer.ILLEGAL, "`break` outside of a loop")
+	}
+
+	if p.isNext(lexer.SEMICOLON) {
+		p.eat()
+	}
+
+	return stmt
+}
+
+func (p *parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.eat()}
+
+	if p.loopDepth == 0 {
+		p.reportAt(stmt.Token, lexer.ILLEGAL, "`continue` outside of a loop")
+	}
+
+	if p.isNext(lexer.SEMICOLON) {
+		p.eat()
+	}
+
+	return stmt
+}
+
+func (p *parser) parseYieldStatement() *ast.YieldStatement {
+	stmt := &ast.YieldStatement{Token: p.eat()}
+
+	if p.function == nil {
+		p.reportAt(stmt.Token, lexer.ILLEGAL, "`yield` outside of a function")
+	} else {
+		p.function.Generator = true
+	}
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.isNext(lexer.SEMICOLON) {
+		p.eat()
+	}
+
+	return stmt
+}
+
+func (p *parser) parseStructStatement() *ast.StructStatement {
+	stmt := &ast.StructStatement{Token: p.eat()}
+
+	if !p.expect(lexer.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Depth: ast.Unresolved}
+
+	if !p.expect(lexer.LBRACE) {
+		return nil
+	}
+
+	for !p.isNext(lexer.RBRACE) {
+		if !p.expect(lexer.IDENT) {
+			return nil
+		}
+		stmt.Fields = append(stmt.Fields, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Depth: ast.Unresolved})
+
+		if !p.isNext(lexer.COMMA) {
+			break
+		}
+		p.eat()
+	}
+
+	if !p.expect(lexer.RBRACE) {
+		return nil
+	}
+
+	return stmt
+}
+
+func (p *parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.eat()}
+
+	if p.blockDepth > 0 {
+		p.reportAt(stmt.Token, lexer.ILLEGAL, "`import` is only allowed at the top level")
+	}
+
+	if !p.expect(lexer.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.isNext(lexer.AS) {
+		p.eat()
+		if !p.expect(lexer.IDENT) {
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Depth: ast.Unresolved}
+	}
+
+	if p.isNext(lexer.SEMICOLON) {
+		p.eat()
+	}
+
+	return stmt
+}
+
+func (p *parser) parseExportStatement() *ast.ExportStatement {
+	stmt := &ast.ExportStatement{Token: p.eat()}
+
+	if p.blockDepth > 0 {
+		p.reportAt(stmt.Token, lexer.ILLEGAL, "`export` is only allowed at the top level")
+	}
+
+	switch p.next().Type {
+	case lexer.LET, lexer.CONST, lexer.STRUCT, lexer.FUNCTION:
+	default:
+		p.errorAt(p.next(), lexer.ILLEGAL, "expected a declaration after `export`, found %s", describe(p.next()))
+		return nil
+	}
+
+	stmt.Statement = p.parseStatement()
+	if p.panicking {
+		return nil
+	}
+
+	if es, ok := stmt.Statement.(*ast.ExpressionStatement); ok {
+		if fl, ok := es.Expression.(*ast.FunctionLiteral); !ok || !fl.Named || fl.Receiver != nil {
+			p.reportAt(stmt.Token, lexer.ILLEGAL, "only named functions can be exported")
+		}
+	}
+
+	return stmt
+}
+
 func (p *parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.next()}
 
@@ -150,18 +349,16 @@ func (p *parser) parseExpressionStatement() *ast.ExpressionStatement {
 func (p *parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.eat()}
 
-	if !p.isNext(lexer.IDENT) {
+	if !p.expect(lexer.IDENT) {
 		return nil
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.eat(), Value: p.curToken.Literal}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Depth: ast.Unresolved}
 
-	if !p.isNext(lexer.ASSIGN) {
+	if !p.expect(lexer.ASSIGN) {
 		return nil
 	}
 
-	p.eat()
-
 	stmt.Value = p.parseExpression(LOWEST)
 
 	if p.isNext(lexer.SEMICOLON) {
@@ -174,18 +371,16 @@ func (p *parser) parseLetStatement() *ast.LetStatement {
 func (p *parser) parseConstStatement() *ast.ConstStatement {
 	stmt := &ast.ConstStatement{Token: p.eat()}
 
-	if !p.isNext(lexer.IDENT) {
+	if !p.expect(lexer.IDENT) {
 		return nil
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.eat(), Value: p.curToken.Literal}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Depth: ast.Unresolved}
 
-	if !p.isNext(lexer.ASSIGN) {
+	if !p.expect(lexer.ASSIGN) {
 		return nil
 	}
 
-	p.eat()
-
 	stmt.Value = p.parseExpression(LOWEST)
 
 	if p.isNext(lexer.SEMICOLON) {
@@ -198,16 +393,21 @@ func (p *parser) parseConstStatement() *ast.ConstStatement {
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // =
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
-	ASSIGN      // =
 	CALL        // myFunction(X)
+	INDEX       // array[index]
 )
 
 var precedences = map[lexer.TokenType]int{
+	lexer.OR:           LOGICAL_OR,
+	lexer.AND:          LOGICAL_AND,
 	lexer.EQ:           EQUALS,
 	lexer.NOT_EQ:       EQUALS,
 	lexer.LT_EQ:        EQUALS,
@@ -226,6 +426,8 @@ var precedences = map[lexer.TokenType]int{
 	lexer.SLASH_ASSIGN: ASSIGN,
 	lexer.MOD_ASSIGN:   ASSIGN,
 	lexer.LPAREN:       CALL,
+	lexer.LBRACKET:     INDEX,
+	lexer.DOT:          INDEX,
 }
 
 func (p *parser) nextPrecedence() int {
@@ -237,14 +439,15 @@ func (p *parser) nextPrecedence() int {
 }
 
 func (p *parser) parseExpression(precedence int) ast.Expression {
-	p.eat()
-	_nud := p.nuds[p.curToken.Type]
+	_nud := p.nuds[p.next().Type]
 	if _nud == nil {
+		p.errorAt(p.next(), lexer.ILLEGAL, "unexpected %s", describe(p.next()))
 		return nil
 	}
+	p.eat()
 	left := _nud()
 
-	for !p.isNext(lexer.SEMICOLON) && precedence < p.nextPrecedence() {
+	for !p.panicking && !p.isNext(lexer.SEMICOLON) && precedence < p.nextPrecedence() {
 		_led := p.leds[p.next().Type]
 		if _led == nil {
 			return left
@@ -258,18 +461,29 @@ func (p *parser) parseExpression(precedence int) ast.Expression {
 }
 
 func (p *parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Depth: ast.Unresolved}
 }
 
 func (p *parser) parseIntegerLiteral() ast.Expression {
 	il, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		panic(err)
+		p.errorAt(p.curToken, lexer.ILLEGAL, "invalid integer literal %s", describe(p.curToken))
+		return nil
 	}
 
 	return &ast.IntegerLiteral{Token: p.curToken, Value: il}
 }
 
+func (p *parser) parseFloatLiteral() ast.Expression {
+	fl, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.errorAt(p.curToken, lexer.ILLEGAL, "invalid float literal %s", describe(p.curToken))
+		return nil
+	}
+
+	return &ast.FloatLiteral{Token: p.curToken, Value: fl}
+}
+
 func (p *parser) parseBooleanLiteral() ast.Expression {
 	return &ast.BooleanLiteral{Token: p.curToken, Value: p.curToken.Type == lexer.TRUE}
 }
@@ -281,12 +495,10 @@ func (p *parser) parseStringLiteral() ast.Expression {
 func (p *parser) parseGroupedExpression() ast.Expression {
 	exp := p.parseExpression(LOWEST)
 
-	if !p.isNext(lexer.RPAREN) {
+	if !p.expect(lexer.RPAREN) {
 		return nil
 	}
 
-	p.eat()
-
 	return exp
 }
 
@@ -298,6 +510,10 @@ func (p *parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := precedences[p.curToken.Type]
+	if precedence == ASSIGN {
+		// assignment is right associative: a = b = c is a = (b = c)
+		precedence = LOWEST
+	}
 	expr.Right = p.parseExpression(precedence)
 
 	return expr
@@ -309,8 +525,7 @@ func (p *parser) parsePrefixExpression() ast.Expression {
 		Operator: p.curToken.Literal,
 	}
 
-	precedence := precedences[p.curToken.Type]
-	expr.Right = p.parseExpression(precedence)
+	expr.Right = p.parseExpression(PREFIX)
 
 	return expr
 }
@@ -319,24 +534,41 @@ func (p *parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken, Named: false}
 
 	if p.isNext(lexer.IDENT) {
-		lit.Name = &ast.Identifier{Token: p.eat(), Value: p.curToken.Literal}
+		lit.Name = &ast.Identifier{Token: p.eat(), Value: p.curToken.Literal, Depth: ast.Unresolved}
 		lit.Named = true
+
+		if p.isNext(lexer.DOT) {
+			p.eat()
+			if !p.expect(lexer.IDENT) {
+				return nil
+			}
+			lit.Receiver = lit.Name
+			lit.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Depth: ast.Unresolved}
+		}
 	}
 
-	if !p.isNext(lexer.LPAREN) {
+	if !p.expect(lexer.LPAREN) {
 		return nil
 	}
 
-	p.eat()
-
 	lit.Parameters = p.parseFunctionParams()
+	if lit.Parameters == nil {
+		return nil
+	}
 
-	if !p.isNext(lexer.LBRACE) {
+	if !p.expect(lexer.LBRACE) {
 		return nil
 	}
-	p.eat()
 
+	// a loop around the function literal does not make break or continue
+	// valid inside its body
+	loopDepth, function := p.loopDepth, p.function
+	p.loopDepth, p.function = 0, lit
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth, p.function = loopDepth, function
+	if lit.Body == nil {
+		return nil
+	}
 
 	return lit
 }
@@ -349,19 +581,24 @@ func (p *parser) parseFunctionParams() []*ast.Identifier {
 		return idents
 	}
 
-	ident := &ast.Identifier{Token: p.eat(), Value: p.curToken.Literal}
+	if !p.expect(lexer.IDENT) {
+		return nil
+	}
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Depth: ast.Unresolved}
 	idents = append(idents, ident)
 
 	for p.isNext(lexer.COMMA) {
 		p.eat()
-		ident := &ast.Identifier{Token: p.eat(), Value: p.curToken.Literal}
+		if !p.expect(lexer.IDENT) {
+			return nil
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Depth: ast.Unresolved}
 		idents = append(idents, ident)
 	}
 
-	if !p.isNext(lexer.RPAREN) {
+	if !p.expect(lexer.RPAREN) {
 		return nil
 	}
-	p.eat()
 
 	return idents
 }
@@ -369,55 +606,74 @@ func (p *parser) parseFunctionParams() []*ast.Identifier {
 func (p *parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
 	for !p.isNext(lexer.RBRACE) && !p.isNext(lexer.EOF) {
+		start := p.next()
 		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize(start)
+			continue
+		}
 		block.Statements = append(block.Statements, stmt)
 	}
 
+	if !p.expect(lexer.RBRACE) {
+		return nil
+	}
+
 	return block
 }
 
 func (p *parser) parseIfExpression() ast.Expression {
 	exp := &ast.IfExpression{Token: p.curToken}
 
-	if !p.isNext(lexer.LPAREN) {
+	if !p.expect(lexer.LPAREN) {
 		return nil
 	}
-	p.eat()
 
 	exp.Condition = p.parseExpression(LOWEST)
 
-	if !p.isNext(lexer.RPAREN) {
+	if !p.expect(lexer.RPAREN) {
 		return nil
 	}
-	p.eat()
 
-	if !p.isNext(lexer.LBRACE) {
+	if !p.expect(lexer.LBRACE) {
 		return nil
 	}
-	p.eat()
 
 	exp.Consequence = p.parseBlockStatement()
-
-	if !p.isNext(lexer.RBRACE) {
+	if exp.Consequence == nil {
 		return nil
 	}
-	p.eat()
 
 	if p.isNext(lexer.ELSE) {
 		p.eat()
 
-		if !p.isNext(lexer.LBRACE) {
+		if p.isNext(lexer.IF) {
+			// else if (...) { } is an alternative block holding just the
+			// nested if, so chains of any length evaluate by recursion
+			tok := p.eat()
+			nested := p.parseIfExpression()
+			if nested == nil {
+				return nil
+			}
+			exp.Alternative = &ast.BlockStatement{
+				Token:      tok,
+				Statements: []ast.Statement{&ast.ExpressionStatement{Token: tok, Expression: nested}},
+			}
+			return exp
+		}
+
+		if !p.expect(lexer.LBRACE) {
 			return nil
 		}
-		p.eat()
 
 		exp.Alternative = p.parseBlockStatement()
-
-		if !p.isNext(lexer.RBRACE) {
+		if exp.Alternative == nil {
 			return nil
 		}
-		p.eat()
 	}
 
 	return exp
@@ -425,74 +681,233 @@ func (p *parser) parseIfExpression() ast.Expression {
 
 func (p *parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseExpressionList(lexer.RPAREN)
+	if exp.Arguments == nil {
+		return nil
+	}
 	return exp
 }
 
-func (p *parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
+func (p *parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(lexer.RBRACKET)
+	if array.Elements == nil {
+		return nil
+	}
+	return array
+}
 
-	if p.isNext(lexer.RPAREN) {
+func (p *parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+
+	for !p.isNext(lexer.RBRACE) {
+		hash.Keys = append(hash.Keys, p.parseExpression(LOWEST))
+
+		if !p.expect(lexer.COLON) {
+			return nil
+		}
+
+		hash.Values = append(hash.Values, p.parseExpression(LOWEST))
+
+		if !p.isNext(lexer.COMMA) {
+			break
+		}
+		p.eat()
+	}
+
+	if !p.expect(lexer.RBRACE) {
+		return nil
+	}
+
+	return hash
+}
+
+func (p *parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: left}
+
+	if !p.expect(lexer.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Depth: ast.Unresolved}
+
+	return exp
+}
+
+func (p *parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expect(lexer.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+// parseExpressionList parses comma separated expressions up to and including
+// the end token.
+func (p *parser) parseExpressionList(end lexer.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+
+	if p.isNext(end) {
 		p.eat()
-		return args
+		return list
 	}
 
-	args = append(args, p.parseExpression(LOWEST))
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.isNext(lexer.COMMA) {
 		p.eat()
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.isNext(lexer.RPAREN) {
+	if !p.expect(end) {
 		return nil
 	}
-	p.eat()
 
-	return args
+	return list
 }
 
 func (p *parser) parseForExpression() ast.Expression {
 	exp := &ast.ForExpression{Token: p.curToken, ConditionOnly: false}
 
-	if !p.isNext(lexer.LPAREN) {
+	if !p.expect(lexer.LPAREN) {
 		return nil
 	}
-	p.eat()
 
 	if p.next().Type == lexer.LET {
 
 		exp.Initializer = p.parseLetStatement()
+		if exp.Initializer == nil {
+			return nil
+		}
 
 		exp.Condition = p.parseExpression(LOWEST)
 
-		if !p.isNext(lexer.SEMICOLON) {
+		if !p.expect(lexer.SEMICOLON) {
 			return nil
 		}
-		p.eat()
 
 		exp.Post = p.parseExpressionStatement()
 	} else {
+		condition := p.parseExpression(LOWEST)
+		if ident, ok := condition.(*ast.Identifier); ok && (p.isNext(lexer.IN) || p.isNext(lexer.COMMA)) {
+			return p.parseForInExpression(exp.Token, ident)
+		}
 		exp.ConditionOnly = true
-		exp.Condition = p.parseExpression(LOWEST)
+		exp.Condition = condition
 	}
 
-	if !p.isNext(lexer.RPAREN) {
+	exp.Body = p.parseLoopBody()
+	if exp.Body == nil {
 		return nil
 	}
-	p.eat()
 
-	if !p.isNext(lexer.LBRACE) {
+	return exp
+}
+
+// parseForInExpression continues a for loop whose header began with the
+// identifier first followed by `in` or `,`.
+func (p *parser) parseForInExpression(tok *lexer.Token, first *ast.Identifier) ast.Expression {
+	exp := &ast.ForInExpression{Token: tok, Value: first}
+
+	if p.isNext(lexer.COMMA) {
+		p.eat()
+
+		if !p.expect(lexer.IDENT) {
+			return nil
+		}
+		exp.Key = first
+		exp.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Depth: ast.Unresolved}
+	}
+
+	if !p.expect(lexer.IN) {
 		return nil
 	}
-	p.eat()
 
-	exp.Body = p.parseBlockStatement()
+	exp.Iterable = p.parseExpression(LOWEST)
 
-	if !p.isNext(lexer.RBRACE) {
+	exp.Body = p.parseLoopBody()
+	if exp.Body == nil {
+		return nil
+	}
+
+	return exp
+}
+
+// parseLoopBody parses the `) { ... }` that ends every for loop.
+func (p *parser) parseLoopBody() *ast.BlockStatement {
+	if !p.expect(lexer.RPAREN) {
+		return nil
+	}
+
+	if !p.expect(lexer.LBRACE) {
+		return nil
+	}
+
+	p.loopDepth++
+	body := p.parseBlockStatement()
+	p.loopDepth--
+
+	return body
+}
+
+func (p *parser) parseTryExpression() ast.Expression {
+	exp := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expect(lexer.LBRACE) {
+		return nil
+	}
+
+	exp.Block = p.parseBlockStatement()
+	if exp.Block == nil {
+		return nil
+	}
+
+	if p.isNext(lexer.CATCH) {
+		p.eat()
+
+		if p.isNext(lexer.LPAREN) {
+			p.eat()
+
+			if !p.expect(lexer.IDENT) {
+				return nil
+			}
+			exp.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Depth: ast.Unresolved}
+
+			if !p.expect(lexer.RPAREN) {
+				return nil
+			}
+		}
+
+		if !p.expect(lexer.LBRACE) {
+			return nil
+		}
+
+		exp.Catch = p.parseBlockStatement()
+		if exp.Catch == nil {
+			return nil
+		}
+	}
+
+	if p.isNext(lexer.FINALLY) {
+		p.eat()
+
+		if !p.expect(lexer.LBRACE) {
+			return nil
+		}
+
+		exp.Finally = p.parseBlockStatement()
+		if exp.Finally == nil {
+			return nil
+		}
+	}
+
+	if exp.Catch == nil && exp.Finally == nil {
+		p.errorAt(p.next(), lexer.CATCH, "expected `catch` or `finally`, found %s", describe(p.next()))
 		return nil
 	}
-	p.eat()
 
 	return exp
 }
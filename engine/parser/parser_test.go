@@ -0,0 +1,91 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package parser
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/danecwalker/ponic/engine/lexer"
+)
+
+func parse(src string) []Diagnostic {
+	_, diagnostics := NewParser(lexer.NewLexer(bufio.NewReader(strings.NewReader(src)))).Parse()
+	return diagnostics
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "brace closing a failed top-level statement",
+			src: `
+if (x { print(1); }
+let y = 2;`,
+			want: []string{"2:7: expected `)`, found `{`"},
+		},
+		{
+			name: "else of a failed if",
+			src: `
+if (x { print(1); } else { print(2); }
+let y = 2;`,
+			want: []string{"2:7: expected `)`, found `{`"},
+		},
+		{
+			name: "brace after a failed expression",
+			src: `
+let x = 1 + ) }
+let y = 2;`,
+			want: []string{"2:13: unexpected `)`"},
+		},
+		{
+			name: "brace closing the enclosing block",
+			src: `
+fn f() {
+	let x = 1 + );
+}
+let y = ;`,
+			want: []string{"3:14: unexpected `)`", "5:9: unexpected `;`"},
+		},
+		{
+			name: "errors in separate statements",
+			src: `
+let a = ;
+let b = 1;
+let c = );`,
+			want: []string{"2:9: unexpected `;`", "4:9: unexpected `)`"},
+		},
+		{
+			name: "unterminated string",
+			src: `
+let s = "abc;
+print(s);`,
+			want: []string{"2:9: unexpected unterminated string"},
+		},
+		{
+			name: "unterminated comment",
+			src:  `print(1 /* never closed`,
+			want: []string{"1:9: expected `)`, found unterminated comment"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics := parse(tt.src)
+			var got []string
+			for _, d := range diagnostics {
+				got = append(got, d.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got diagnostics\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
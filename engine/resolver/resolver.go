@@ -0,0 +1,326 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package resolver binds every identifier of a program to the variable it
+// names before the program runs.
+//
+// The resolver gives each local variable a slot in the scope that declares
+// it and records on each identifier how many scopes out that is, so the
+// runtime can find a variable without searching for it by name. Its scopes
+// are the ones the runtime creates: one for the top level of a module, one
+// for each call of a function holding its parameters and its body, one for
+// the variables of each for loop, one for each iteration of a for-in loop
+// holding the loop variables and the body, one for each catch clause
+// holding its parameter and its body, and one for every other block.
+//
+// A variable can be used anywhere in its scope, including before its
+// declaration, as long as it has been declared by the time the use runs.
+// This lets functions refer to each other whatever order they are
+// declared in. A use of a local that comes before its declaration in the
+// same function always runs before it too, so it is reported rather than
+// left to fail at run time. A name can be declared only once in a scope.
+// Variables at the top level of a module are globals, looked up by name.
+package resolver
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/lexer"
+)
+
+// Error is a use of a variable the resolver rejects.
+type Error struct {
+	Message string
+	Pos     lexer.Position
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Message)
+}
+
+// Globals reports whether name is bound outside of the program, and if so
+// whether it is a constant. It describes builtins and the bindings left at
+// the top level of the module by programs run in it before.
+type Globals func(name string) (constant bool, ok bool)
+
+type binding struct {
+	slot     int
+	constant bool
+	// declared is the number of references seen before the declaration
+	declared int
+}
+
+type scope struct {
+	parent *scope
+	names  map[string]*binding
+	// global is set on the scope of the top level of the module, and
+	// function on the scope of each call of a function
+	global, function bool
+}
+
+// reference is a use of an identifier, resolved once the whole program has
+// been seen so that it can refer to a variable declared after it. Its index
+// in the references of the program orders it against declarations.
+type reference struct {
+	ident  *ast.Identifier
+	scope  *scope
+	assign bool
+	index  int
+}
+
+type resolver struct {
+	current *scope
+	refs    []reference
+	globals Globals
+	errors  []*Error
+}
+
+// Resolve sets the Depth and Slot of the identifiers in program, to run at
+// the top level of a module whose existing globals are described by
+// globals. It returns the uses of undefined variables, the uses of locals
+// before their declaration, the assignments to constants and the names
+// declared twice in a scope it found, in the order they appear in.
+func Resolve(program *ast.AST, globals Globals) []*Error {
+	r := &resolver{globals: globals}
+	r.current = &scope{names: make(map[string]*binding), global: true}
+	r.statements(program.Statements)
+
+	for _, ref := range r.refs {
+		if err := r.resolve(ref); err != nil {
+			r.errors = append(r.errors, err)
+		}
+	}
+	sort.SliceStable(r.errors, func(i, j int) bool {
+		a, b := r.errors[i].Pos, r.errors[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return r.errors
+}
+
+func (r *resolver) resolve(ref reference) *Error {
+	name := ref.ident.Value
+	depth := 0
+	// nested is set once the search leaves the function the reference is
+	// in, which may be called after the declarations that follow it
+	nested := false
+	for s := ref.scope; s != nil; s = s.parent {
+		b, ok := s.names[name]
+		if !ok {
+			if !s.global {
+				depth++
+			}
+			nested = nested || s.function
+			continue
+		}
+
+		if !s.global && !nested && ref.index < b.declared {
+			return &Error{Message: name + " used before its declaration", Pos: ref.ident.Pos()}
+		}
+
+		if s.global {
+			ref.ident.Depth = ast.Global
+		} else {
+			ref.ident.Depth, ref.ident.Slot = depth, b.slot
+		}
+		if ref.assign && b.constant {
+			return &Error{Message: "Cannot reassign constant " + name, Pos: ref.ident.Pos()}
+		}
+		return nil
+	}
+
+	ref.ident.Depth = ast.Global
+	constant, ok := r.globals(name)
+	if !ok {
+		return &Error{Message: "Undefined variable " + name, Pos: ref.ident.Pos()}
+	}
+	if ref.assign && constant {
+		return &Error{Message: "Cannot reassign constant " + name, Pos: ref.ident.Pos()}
+	}
+	return nil
+}
+
+func (r *resolver) enterScope() {
+	r.current = &scope{parent: r.current, names: make(map[string]*binding)}
+}
+
+func (r *resolver) leaveScope() {
+	r.current = r.current.parent
+}
+
+// declare binds ident in the current scope.
+func (r *resolver) declare(ident *ast.Identifier, constant bool) {
+	b := r.declareName(ident.Value, ident.Pos(), constant)
+	if r.current.global {
+		ident.Depth = ast.Global
+	} else {
+		ident.Depth, ident.Slot = 0, b.slot
+	}
+}
+
+// declareName binds name, declared at pos, in the current scope. A name
+// declared again in the same scope is reported and keeps its first slot.
+func (r *resolver) declareName(name string, pos lexer.Position, constant bool) *binding {
+	if b, ok := r.current.names[name]; ok {
+		r.errors = append(r.errors, &Error{Message: "Cannot redeclare " + name, Pos: pos})
+		return b
+	}
+	b := &binding{slot: len(r.current.names), constant: constant, declared: len(r.refs)}
+	r.current.names[name] = b
+	return b
+}
+
+func (r *resolver) use(ident *ast.Identifier, assign bool) {
+	r.refs = append(r.refs, reference{ident: ident, scope: r.current, assign: assign, index: len(r.refs)})
+}
+
+func (r *resolver) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		r.node(stmt)
+	}
+}
+
+func (r *resolver) expressions(exps []ast.Expression) {
+	for _, exp := range exps {
+		r.node(exp)
+	}
+}
+
+func (r *resolver) node(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		r.node(node.Expression)
+	case *ast.BlockStatement:
+		r.enterScope()
+		r.statements(node.Statements)
+		r.leaveScope()
+	case *ast.LetStatement:
+		r.node(node.Value)
+		r.declare(node.Name, false)
+	case *ast.ConstStatement:
+		r.node(node.Value)
+		r.declare(node.Name, true)
+	case *ast.StructStatement:
+		r.declare(node.Name, true)
+	case *ast.ImportStatement:
+		if node.Alias != nil {
+			r.declare(node.Alias, true)
+		} else {
+			r.declareName(importName(node.Path.Value), node.Path.Pos(), true)
+		}
+	case *ast.ExportStatement:
+		r.node(node.Statement)
+	case *ast.ReturnStatement:
+		r.node(node.ReturnValue)
+	case *ast.YieldStatement:
+		r.node(node.Value)
+	case *ast.ThrowStatement:
+		r.node(node.Value)
+	case *ast.Identifier:
+		r.use(node, false)
+	case *ast.UnOp:
+		r.node(node.Right)
+	case *ast.BinOp:
+		if ident, ok := node.Left.(*ast.Identifier); ok && isAssignment(node.Operator) {
+			r.use(ident, true)
+		} else {
+			r.node(node.Left)
+		}
+		r.node(node.Right)
+	case *ast.ArrayLiteral:
+		r.expressions(node.Elements)
+	case *ast.HashLiteral:
+		r.expressions(node.Keys)
+		r.expressions(node.Values)
+	case *ast.IndexExpression:
+		r.node(node.Left)
+		r.node(node.Index)
+	case *ast.MemberExpression:
+		r.node(node.Object)
+	case *ast.CallExpression:
+		r.node(node.Function)
+		r.expressions(node.Arguments)
+	case *ast.IfExpression:
+		r.node(node.Condition)
+		r.node(node.Consequence)
+		if node.Alternative != nil {
+			r.node(node.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		r.function(node)
+	case *ast.ForExpression:
+		r.enterScope()
+		if node.Initializer != nil {
+			r.node(node.Initializer)
+		}
+		r.node(node.Condition)
+		r.node(node.Body)
+		if node.Post != nil {
+			r.node(node.Post)
+		}
+		r.leaveScope()
+	case *ast.ForInExpression:
+		r.node(node.Iterable)
+		r.enterScope()
+		if node.Key != nil {
+			r.declare(node.Key, false)
+		}
+		r.declare(node.Value, false)
+		r.statements(node.Body.Statements)
+		r.leaveScope()
+	case *ast.TryExpression:
+		r.node(node.Block)
+		if node.Catch != nil {
+			r.enterScope()
+			if node.Param != nil {
+				r.declare(node.Param, false)
+			}
+			r.statements(node.Catch.Statements)
+			r.leaveScope()
+		}
+		if node.Finally != nil {
+			r.node(node.Finally)
+		}
+	}
+}
+
+// function resolves a function literal. A named function is bound in the
+// scope it is declared in, and a method is looked up on the struct named
+// by its receiver. The body runs in the scope of a call, which is nested
+// in the scope the function is created in.
+func (r *resolver) function(fl *ast.FunctionLiteral) {
+	switch {
+	case fl.Receiver != nil:
+		r.use(fl.Receiver, false)
+	case fl.Named:
+		r.declare(fl.Name, false)
+	}
+
+	r.enterScope()
+	r.current.function = true
+	for _, param := range fl.Parameters {
+		r.declare(param, false)
+	}
+	r.statements(fl.Body.Statements)
+	r.leaveScope()
+}
+
+func isAssignment(operator string) bool {
+	switch operator {
+	case "=", "+=", "-=", "*=", "/=", "%=":
+		return true
+	default:
+		return false
+	}
+}
+
+// importName is the name an import without an alias binds: the name of the
+// module.
+func importName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
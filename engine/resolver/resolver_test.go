@@ -0,0 +1,115 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/internal/testutil"
+)
+
+// globals binds the constant g and the variable h outside of the program.
+func globals(name string) (bool, bool) {
+	switch name {
+	case "g":
+		return true, true
+	case "h":
+		return false, true
+	}
+	return false, false
+}
+
+func TestSlots(t *testing.T) {
+	program := testutil.Parse(t, `
+fn f(a, b) {
+	let c = 1;
+	return fn() { return [c, b, a, f, g]; };
+}`)
+	if errs := Resolve(program, globals); len(errs) > 0 {
+		t.Fatalf("unexpected error: %s", errs[0])
+	}
+
+	outer := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	inner := outer.Body.Statements[1].(*ast.ReturnStatement).ReturnValue.(*ast.FunctionLiteral)
+	uses := inner.Body.Statements[0].(*ast.ReturnStatement).ReturnValue.(*ast.ArrayLiteral).Elements
+
+	want := []struct{ depth, slot int }{
+		{1, 2},
+		{1, 1},
+		{1, 0},
+		{ast.Global, 0},
+		{ast.Global, 0},
+	}
+	for n, use := range uses {
+		ident := use.(*ast.Identifier)
+		if ident.Depth != want[n].depth || ident.Slot != want[n].slot {
+			t.Errorf("%s: got depth %d slot %d, want depth %d slot %d", ident.Value, ident.Depth, ident.Slot, want[n].depth, want[n].slot)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "globals",
+			src:  `h = g; h += 1;`,
+		},
+		{
+			name: "constant global",
+			src:  `g = 1;`,
+			want: []string{"1:1: Cannot reassign constant g"},
+		},
+		{
+			name: "declared after use",
+			src:  `fn f() { return later(); } fn later() { return 1; }`,
+		},
+		{
+			name: "local used before its declaration",
+			src: `
+let x = 1;
+fn f() { let y = x; let x = 2; }`,
+			want: []string{"3:18: x used before its declaration"},
+		},
+		{
+			name: "local used before its declaration in a nested function",
+			src:  `fn f() { let get = fn() { return x; }; let x = 2; return get(); }`,
+		},
+		{
+			name: "every error in source order",
+			src: `
+fn f() { return y; }
+x = 1;
+const k = 1;
+fn raise() { k = 2; }
+let z = 1;
+let z = 2;`,
+			want: []string{
+				"2:17: Undefined variable y",
+				"3:1: Undefined variable x",
+				"5:14: Cannot reassign constant k",
+				"7:5: Cannot redeclare z",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range Resolve(testutil.Parse(t, tt.src), globals) {
+				got = append(got, err.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got errors\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -6,53 +6,289 @@
 package runtime
 
 import (
-	"fmt"
 	"strconv"
+	"unicode/utf8"
 
 	"github.com/danecwalker/ponic/engine/object"
 )
 
-type Builtin = func(args ...object.Object) object.Object
+type Builtin = object.BuiltinFunction
 
 var Builtins = map[string]Builtin{
-	"print": _print,
+	"print":  Stdio.print,
+	"eprint": Stdio.eprint,
 
-	"scan": _scan,
+	"scan": Stdio.scan,
 
 	"int": _int,
-}
 
-func _print(args ...object.Object) object.Object {
-	_args := make([]interface{}, len(args))
-	for i, arg := range args {
-		_args[i] = unescape(arg.Inspect())
-	}
-	fmt.Print(_args...)
-	return nil
-}
+	"float": _float,
 
-func _scan(args ...object.Object) object.Object {
-	var input string
-	_print(args...)
-	fmt.Scan(&input)
-	return &object.String{Value: input}
+	"len":   _len,
+	"push":  _push,
+	"pop":   _pop,
+	"slice": _slice,
+
+	"keys":   _keys,
+	"values": _values,
+	"has":    _has,
+	"delete": _delete,
+
+	"range": _range,
+	"iter":  _iter,
+	"next":  _next,
 }
 
 func _int(args ...object.Object) object.Object {
 	if len(args) != 1 {
-		panic("wrong number of arguments.")
+		return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
 	}
 
 	switch arg := args[0].(type) {
 	case *object.Integer:
 		return arg
+	case *object.Float:
+		return &object.Integer{Value: int64(arg.Value)}
 	case *object.String:
 		i, err := strconv.ParseInt(arg.Value, 10, 64)
 		if err != nil {
-			panic(err)
+			return object.NewError("cannot convert \"%s\" to int", arg.Value)
 		}
 		return &object.Integer{Value: i}
 	default:
-		panic("argument to `int` not supported.")
+		return object.NewError("argument to `int` not supported, got %s", arg.Inspect())
+	}
+}
+
+func _float(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Float:
+		return arg
+	case *object.Integer:
+		return &object.Float{Value: float64(arg.Value)}
+	case *object.String:
+		f, err := strconv.ParseFloat(arg.Value, 64)
+		if err != nil {
+			return object.NewError("cannot convert \"%s\" to float", arg.Value)
+		}
+		return &object.Float{Value: f}
+	default:
+		return object.NewError("argument to `float` not supported, got %s", arg.Inspect())
+	}
+}
+
+// _len counts the elements of an array or hash, or the characters of a
+// string: its runes, as a for-in loop visits them, not its bytes.
+func _len(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(len(arg.Order))}
+	default:
+		return object.NewError("argument to `len` not supported, got %s", arg.Inspect())
+	}
+}
+
+// _push appends the remaining arguments to the array in place and returns it.
+func _push(args ...object.Object) object.Object {
+	if len(args) < 2 {
+		return object.NewError("wrong number of arguments: expected at least 2, got %d", len(args))
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return object.NewError("first argument to `push` must be an array, got %s", args[0].Inspect())
+	}
+	arr.Elements = append(arr.Elements, args[1:]...)
+	return arr
+}
+
+// _pop removes the last element of the array and returns it.
+func _pop(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return object.NewError("argument to `pop` must be an array, got %s", args[0].Inspect())
+	}
+	if len(arr.Elements) == 0 {
+		return object.NewError("pop from empty array")
+	}
+	last := arr.Elements[len(arr.Elements)-1]
+	arr.Elements = arr.Elements[:len(arr.Elements)-1]
+	return last
+}
+
+// _slice returns a new array holding arr[start:end]; end defaults to the
+// length of the array.
+func _slice(args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return object.NewError("wrong number of arguments: expected 2 or 3, got %d", len(args))
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return object.NewError("first argument to `slice` must be an array, got %s", args[0].Inspect())
+	}
+
+	bounds := []int64{0, int64(len(arr.Elements))}
+	for i, arg := range args[1:] {
+		n, ok := arg.(*object.Integer)
+		if !ok {
+			return object.NewError("slice bounds must be integers, got %s", arg.Inspect())
+		}
+		bounds[i] = n.Value
+	}
+
+	start, end := bounds[0], bounds[1]
+	if start < 0 || end < start || end > int64(len(arr.Elements)) {
+		return object.NewError("slice bounds [%d:%d] out of range with length %d", start, end, len(arr.Elements))
+	}
+
+	elements := make([]object.Object, end-start)
+	copy(elements, arr.Elements[start:end])
+	return &object.Array{Elements: elements}
+}
+
+func _keys(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+	}
+
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return object.NewError("argument to `keys` must be a hash, got %s", args[0].Inspect())
+	}
+
+	keys := []object.Object{}
+	for _, pair := range hash.Entries() {
+		keys = append(keys, pair.Key)
+	}
+	return &object.Array{Elements: keys}
+}
+
+func _values(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+	}
+
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return object.NewError("argument to `values` must be a hash, got %s", args[0].Inspect())
+	}
+
+	values := []object.Object{}
+	for _, pair := range hash.Entries() {
+		values = append(values, pair.Value)
+	}
+	return &object.Array{Elements: values}
+}
+
+func _has(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return object.NewError("wrong number of arguments: expected 2, got %d", len(args))
+	}
+
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return object.NewError("first argument to `has` must be a hash, got %s", args[0].Inspect())
+	}
+	key, err := hashKey(args[1])
+	if err != nil {
+		return err
+	}
+
+	_, ok = hash.Get(key)
+	return &object.Boolean{Value: ok}
+}
+
+// _delete removes a key from the hash and reports whether it was present.
+func _delete(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return object.NewError("wrong number of arguments: expected 2, got %d", len(args))
+	}
+
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return object.NewError("first argument to `delete` must be a hash, got %s", args[0].Inspect())
+	}
+	key, err := hashKey(args[1])
+	if err != nil {
+		return err
+	}
+
+	return &object.Boolean{Value: hash.Delete(key)}
+}
+
+// _range returns a lazy range: range(end), range(start, end) or
+// range(start, end, step).
+func _range(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return object.NewError("wrong number of arguments: expected 1 to 3, got %d", len(args))
+	}
+
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		n, ok := arg.(*object.Integer)
+		if !ok {
+			return object.NewError("arguments to `range` must be integers, got %s", arg.Inspect())
+		}
+		bounds[i] = n.Value
+	}
+
+	switch len(bounds) {
+	case 1:
+		return &object.Range{Start: 0, End: bounds[0], Step: 1}
+	case 2:
+		return &object.Range{Start: bounds[0], End: bounds[1], Step: 1}
+	default:
+		if bounds[2] == 0 {
+			return object.NewError("range step cannot be zero")
+		}
+		return &object.Range{Start: bounds[0], End: bounds[1], Step: bounds[2]}
+	}
+}
+
+func _iter(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+	}
+
+	iter, err := iterate(args[0])
+	if err != nil {
+		return err
+	}
+	return iter
+}
+
+// _next advances an iterator and returns its next value, or null once it is
+// exhausted.
+func _next(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+	}
+
+	iter, ok := args[0].(object.Iterator)
+	if !ok {
+		return object.NewError("argument to `next` must be an iterator, got %s", args[0].Inspect())
+	}
+
+	val, ok := iter.Next()
+	if !ok {
+		return &object.Null{}
 	}
+	return val
 }
@@ -0,0 +1,181 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package runtime
+
+import (
+	goruntime "runtime"
+
+	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+// generator runs the body of a function that contains a yield statement.
+// The body runs on its own goroutine in lockstep with the consumer: Next
+// resumes it and waits until it yields a value or finishes, so only one side
+// is ever running.
+type generator struct {
+	body  *ast.BlockStatement
+	scope *object.Scope
+
+	started bool
+	done    bool
+	values  chan object.Object
+	resume  chan bool
+	// abandoned is closed once no one can resume the generator
+	abandoned chan struct{}
+}
+
+// generatorClosed unwinds the body of a generator that was closed while it
+// was suspended at a yield. try expressions do not catch it, but finally
+// blocks still run.
+type generatorClosed struct{}
+
+func (gc *generatorClosed) Type() object.Type {
+	return object.NULL
+}
+func (gc *generatorClosed) Inspect() string {
+	return "generator closed"
+}
+func (gc *generatorClosed) String() string {
+	return "GeneratorClosed()"
+}
+
+// the generator is bound in the scope of its call under the name of the
+// yield keyword, which no Ponic identifier can shadow
+const generatorBinding = "yield"
+
+// generatorIter is the iterator a call to a generator function returns.
+// The goroutine running the body refers to the generator but never to its
+// generatorIter, so once Ponic code drops a generator that has not finished,
+// the generatorIter becomes unreachable and its finalizer abandons the
+// generator, which ends the goroutine. The finalizer runs concurrently with
+// the program, so the body is not unwound through its finally blocks as
+// Close does; only closing a generator runs them.
+type generatorIter struct {
+	*generator
+}
+
+func newGenerator(fn *object.Function, scope *object.Scope) *generatorIter {
+	g := &generator{
+		body:      fn.Body,
+		scope:     scope,
+		values:    make(chan object.Object),
+		resume:    make(chan bool, 1),
+		abandoned: make(chan struct{}),
+	}
+	scope.Set(generatorBinding, g, object.CONST)
+
+	it := &generatorIter{g}
+	goruntime.SetFinalizer(it, func(it *generatorIter) { close(it.abandoned) })
+	return it
+}
+
+// Next keeps it reachable until the generator has handed over its value, so
+// that the finalizer cannot abandon the generator while it runs.
+func (it *generatorIter) Next() (object.Object, bool) {
+	val, ok := it.generator.Next()
+	goruntime.KeepAlive(it)
+	return val, ok
+}
+
+func (it *generatorIter) Close() {
+	it.generator.Close()
+	goruntime.KeepAlive(it)
+}
+
+func (g *generator) Next() (object.Object, bool) {
+	if g.done {
+		return nil, false
+	}
+
+	if !g.started {
+		g.started = true
+		go g.run()
+	} else {
+		g.resume <- true
+	}
+
+	val, ok := <-g.values
+	if !ok || isError(val) {
+		g.done = true
+	}
+	return val, ok
+}
+
+// run runs the body to completion. A Go panic in the body, such as one in
+// a builtin it calls, ends the generator with an error rather than taking
+// down the process from a goroutine no caller can recover.
+func (g *generator) run() {
+	defer close(g.values)
+	defer func() {
+		if r := recover(); r != nil {
+			g.values <- object.NewError("panic in generator: %v", r).In(fileOf(g.scope))
+		}
+	}()
+
+	result := runBlockStatement(g.body, g.scope)
+	if err, ok := result.(*object.Error); ok {
+		g.values <- err.In(fileOf(g.scope))
+	}
+}
+
+// yield hands val to the consumer and suspends the body until the next call
+// to Next, or until Close, in which case it returns generatorClosed. If the
+// generator is abandoned instead, the goroutine exits right away without
+// running any more of the body.
+func (g *generator) yield(val object.Object) object.Object {
+	g.values <- val
+	select {
+	case <-g.abandoned:
+		goruntime.Goexit()
+	case resume := <-g.resume:
+		if !resume {
+			return &generatorClosed{}
+		}
+	}
+	return &object.Null{}
+}
+
+// Close stops a suspended generator, letting its body unwind through any
+// finally blocks before returning.
+func (g *generator) Close() {
+	if !g.started || g.done {
+		g.done = true
+		return
+	}
+	g.done = true
+
+	for {
+		g.resume <- false
+		if _, ok := <-g.values; !ok {
+			return
+		}
+	}
+}
+
+func (g *generator) Type() object.Type {
+	return object.ITERATOR
+}
+func (g *generator) Inspect() string {
+	return "generator"
+}
+func (g *generator) String() string {
+	return "Generator()"
+}
+
+func runYieldStatement(ys *ast.YieldStatement, scope *object.Scope) object.Object {
+	val := Run(ys.Value, scope)
+	if isUnwinding(val) {
+		return val
+	}
+
+	binding, _ := scope.Get(generatorBinding)
+	g, ok := binding.(*generator)
+	if !ok {
+		return object.NewError("`yield` outside of a generator").At(ys.Pos())
+	}
+	return g.yield(val)
+}
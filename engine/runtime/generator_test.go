@@ -0,0 +1,92 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package runtime
+
+import (
+	goruntime "runtime"
+	"testing"
+	"time"
+
+	"github.com/danecwalker/ponic/engine/internal/testutil"
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+func TestGeneratorPanic(t *testing.T) {
+	prelude := object.NewScope()
+	prelude.Set("boom", &object.Builtin{Func: func(args ...object.Object) object.Object {
+		panic("kaboom")
+	}}, object.CONST)
+
+	src := `
+fn gen() {
+	yield 1;
+	boom();
+	yield 2;
+}
+let g = gen();
+let first = next(g);
+next(g)`
+	program := testutil.Parse(t, src)
+	l := NewLoader()
+	l.Prelude = prelude
+	got := l.Run(l.NewModule(""), program)
+
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("got %s, want an error", got.Inspect())
+	}
+	if want := "panic in generator: kaboom"; err.Message != want {
+		t.Errorf("got error %q, want %q", err.Message, want)
+	}
+}
+
+// TestAbandonedGeneratorsAreCollected drops generators suspended inside a
+// try with a finally block. Their goroutines must end without running the
+// finally blocks, which would race with the program; run it with -race.
+func TestAbandonedGeneratorsAreCollected(t *testing.T) {
+	before := goruntime.NumGoroutine()
+
+	l := NewLoader()
+	mod := l.NewModule("")
+	got := l.Run(mod, testutil.Parse(t, `
+let cleanups = {"count": 0};
+fn naturals() {
+	let i = 0;
+	try {
+		for (true) {
+			yield i;
+			i += 1;
+		}
+	} finally {
+		cleanups["count"] += 1;
+	}
+}
+fn firstTwo() {
+	let g = naturals();
+	return [next(g), next(g)];
+}
+let got = [];
+for (i in range(50)) { got = firstTwo(); }
+for (v in naturals()) { if (v == 3) { break; } }
+got`))
+	if got.Inspect() != "[0, 1]" {
+		t.Fatalf("got %s, want [0, 1]", got.Inspect())
+	}
+
+	for i := 0; i < 100 && goruntime.NumGoroutine() > before; i++ {
+		goruntime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := goruntime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines still running after the generators were dropped, want %d", n, before)
+	}
+
+	// only the generator closed by the for-in loop ran its finally block
+	cleanups, _ := mod.Scope.Get("cleanups")
+	if got := cleanups.Inspect(); got != `{"count": 1}` {
+		t.Errorf("got cleanups %s, want {\"count\": 1}", got)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package runtime
+
+import (
+	"strings"
+
+	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+func runIndexExpression(left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		i, err := arrayIndex(left, index)
+		if err != nil {
+			return err
+		}
+		return left.Elements[i]
+	case *object.Hash:
+		key, err := hashKey(index)
+		if err != nil {
+			return err
+		}
+		val, ok := left.Get(key)
+		if !ok {
+			if _, isString := index.(*object.String); isString {
+				return object.NewError("key \"%s\" not found", index.Inspect())
+			}
+			return object.NewError("key %s not found", index.Inspect())
+		}
+		return val
+	default:
+		return object.NewError("index operator not supported on %s", left.Inspect())
+	}
+}
+
+func runIndexAssign(left *ast.IndexExpression, right ast.Expression, operator string, scope *object.Scope) object.Object {
+	container := Run(left.Left, scope)
+	if isError(container) {
+		return container
+	}
+	index := Run(left.Index, scope)
+	if isError(index) {
+		return index
+	}
+	val := Run(right, scope)
+	if isError(val) {
+		return val
+	}
+
+	return assignIndex(container, index, operator, val)
+}
+
+// assignIndex stores val at index of container, first combining it with the
+// current element for a compound operator such as +=.
+func assignIndex(container, index object.Object, operator string, val object.Object) object.Object {
+	if operator != "=" {
+		current := runIndexExpression(container, index)
+		if isError(current) {
+			return current
+		}
+		val = runBinop(strings.TrimSuffix(operator, "="), current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	switch container := container.(type) {
+	case *object.Array:
+		i, err := arrayIndex(container, index)
+		if err != nil {
+			return err
+		}
+		container.Elements[i] = val
+	case *object.Hash:
+		key, err := hashKey(index)
+		if err != nil {
+			return err
+		}
+		container.Set(key, val)
+	default:
+		return object.NewError("index assignment not supported on %s", container.Inspect())
+	}
+
+	return &object.Null{}
+}
+
+func runHashLiteral(node *ast.HashLiteral, scope *object.Scope) object.Object {
+	hash := object.NewHash()
+
+	for i, keyNode := range node.Keys {
+		key := Run(keyNode, scope)
+		if isError(key) {
+			return key
+		}
+		hashable, err := hashKey(key)
+		if err != nil {
+			return err.At(keyNode.Pos())
+		}
+
+		val := Run(node.Values[i], scope)
+		if isError(val) {
+			return val
+		}
+
+		hash.Set(hashable, val)
+	}
+
+	return hash
+}
+
+func hashKey(key object.Object) (object.Hashable, *object.Error) {
+	hashable, ok := key.(object.Hashable)
+	if !ok {
+		return nil, object.NewError("unusable as hash key: %s", key.Inspect())
+	}
+	return hashable, nil
+}
+
+// arrayIndex checks that index is an integer within the bounds of arr.
+func arrayIndex(arr *object.Array, index object.Object) (int, *object.Error) {
+	i, ok := index.(*object.Integer)
+	if !ok {
+		return 0, object.NewError("array index must be an integer, got %s", index.Inspect())
+	}
+	if i.Value < 0 {
+		return 0, object.NewError("negative array index %d", i.Value)
+	}
+	if i.Value >= int64(len(arr.Elements)) {
+		return 0, object.NewError("array index %d out of range with length %d", i.Value, len(arr.Elements))
+	}
+	return int(i.Value), nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package runtime
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+// IO is where the print, eprint and scan builtins write and read. The
+// fields may be changed between runs. scan reads from a Stdin that is a
+// *bufio.Reader directly, so its owner can share the buffered input with
+// scan rather than lose what scan reads ahead.
+type IO struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// reader buffers Stdin across calls to scan
+	reader *bufio.Reader
+	source io.Reader
+}
+
+// Stdio is the IO of the builtins in Builtins, attached to the standard
+// streams of the process.
+var Stdio = &IO{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+
+// Builtins returns print, eprint and scan builtins bound to s, for a scope
+// that shadows the process-wide ones.
+func (s *IO) Builtins() map[string]Builtin {
+	return map[string]Builtin{
+		"print":  s.print,
+		"eprint": s.eprint,
+		"scan":   s.scan,
+	}
+}
+
+func (s *IO) print(args ...object.Object) object.Object {
+	return write(s.Stdout, args)
+}
+
+func (s *IO) eprint(args ...object.Object) object.Object {
+	return write(s.Stderr, args)
+}
+
+func write(w io.Writer, args []object.Object) object.Object {
+	_args := make([]interface{}, len(args))
+	for i, arg := range args {
+		_args[i] = unescape(arg.Inspect())
+	}
+	if _, err := fmt.Fprint(w, _args...); err != nil {
+		return object.NewError("print failed: %s", err)
+	}
+	return &object.Null{}
+}
+
+// scan prints its arguments as a prompt and reads one whitespace separated
+// word from Stdin.
+func (s *IO) scan(args ...object.Object) object.Object {
+	if result := s.print(args...); isError(result) {
+		return result
+	}
+
+	if s.reader == nil || s.source != s.Stdin {
+		s.reader, s.source = bufio.NewReader(s.Stdin), s.Stdin
+	}
+
+	var input string
+	fmt.Fscan(s.reader, &input)
+	return &object.String{Value: input}
+}
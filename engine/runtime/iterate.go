@@ -0,0 +1,93 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package runtime
+
+import (
+	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+// iterate returns an iterator over obj, which must be an iterator itself
+// or an iterable collection.
+func iterate(obj object.Object) (object.Iterator, *object.Error) {
+	switch obj := obj.(type) {
+	case object.Iterator:
+		return obj, nil
+	case object.Iterable:
+		return obj.Iter(), nil
+	default:
+		return nil, object.NewError("%s is not iterable", obj.Inspect())
+	}
+}
+
+// runForInExpression runs the body once per element of the iterable, each
+// time in a fresh scope holding the loop variables. The key variable is the
+// index of the element, except for hashes where the elements are the keys
+// and the value variable holds the value stored under them.
+func runForInExpression(fe *ast.ForInExpression, s *object.Scope) object.Object {
+	iterable := Run(fe.Iterable, s)
+	if isError(iterable) {
+		return iterable
+	}
+
+	iter, err := iterate(iterable)
+	if err != nil {
+		return err.At(fe.Iterable.Pos())
+	}
+	result := runForInLoop(fe, iterable, iter, s)
+	// not deferred: an abandoned generator suspended in this loop exits its
+	// goroutine, which must not run Ponic code by closing iter on the way
+	if closer, ok := iter.(object.Closer); ok {
+		closer.Close()
+	}
+	return result
+}
+
+func runForInLoop(fe *ast.ForInExpression, iterable object.Object, iter object.Iterator, s *object.Scope) object.Object {
+	hash, isHash := iterable.(*object.Hash)
+
+	for i := int64(0); ; i++ {
+		value, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if isError(value) {
+			return value
+		}
+
+		var key object.Object = &object.Integer{Value: i}
+		if isHash && fe.Key != nil {
+			key = value
+			if value, ok = hash.Get(key.(object.Hashable)); !ok {
+				// deleted by an earlier iteration
+				continue
+			}
+		}
+
+		scope := object.NewEnclosedScope(s)
+		if fe.Key != nil {
+			if err := declare(fe.Key, key, object.LET, scope); err != nil {
+				return err
+			}
+		}
+		if err := declare(fe.Value, value, object.LET, scope); err != nil {
+			return err
+		}
+
+		result := runBlockStatement(fe.Body, scope)
+		if isUnwinding(result) {
+			switch result.(type) {
+			case *object.Break:
+				return &object.Null{}
+			case *object.Continue:
+				continue
+			}
+			return result
+		}
+	}
+
+	return &object.Null{}
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package runtime
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/lexer"
+	"github.com/danecwalker/ponic/engine/object"
+	"github.com/danecwalker/ponic/engine/parser"
+	"github.com/danecwalker/ponic/engine/resolver"
+	"github.com/danecwalker/ponic/engine/stdlib"
+)
+
+// Engine runs the top-level code of a module in its scope. A Loader without
+// an Engine runs modules with Run.
+type Engine interface {
+	RunModule(mod *object.Module, program *ast.AST) object.Object
+}
+
+// Loader loads the modules of one program. Each file is run at most once;
+// importing it again returns the cached module.
+type Loader struct {
+	// Prelude, if set, is the parent of the top-level scope of every .pc
+	// module, for bindings shared by all of them.
+	Prelude *object.Scope
+	Engine  Engine
+
+	modules map[string]*object.Module
+	// loading is the chain of modules currently being run, outermost
+	// first, for detecting import cycles
+	loading []*object.Module
+	// depth counts the calls of functions of the program in progress
+	depth int
+}
+
+func NewLoader() *Loader {
+	return &Loader{modules: make(map[string]*object.Module)}
+}
+
+// NewModule returns an empty module for the file at path, which imports
+// through l. An empty path makes a module that is not backed by a file;
+// its imports resolve relative to the working directory.
+func (l *Loader) NewModule(path string) *object.Module {
+	scope := object.NewScope()
+	scope.Parent = l.Prelude
+
+	name := "main"
+	if path != "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	mod := &object.Module{
+		Name:     name,
+		Path:     path,
+		Scope:    scope,
+		Exports:  make(map[string]bool),
+		Importer: l,
+	}
+	scope.Module = mod
+	return mod
+}
+
+// Exec runs program as the module at path and returns the module together
+// with the result of its last statement.
+func (l *Loader) Exec(path string, program *ast.AST) (*object.Module, object.Object) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return nil, object.NewError("cannot load %s: %s", path, err).In(path)
+	}
+
+	mod := l.NewModule(path)
+	l.modules[key] = mod
+	result := l.Run(mod, program)
+	if isError(result) {
+		delete(l.modules, key)
+	}
+	return mod, result
+}
+
+// Run runs program in the top-level scope of mod, which may already hold
+// the bindings of earlier runs. Nothing is run if resolving the program
+// finds an undefined variable or an assignment to a constant.
+func (l *Loader) Run(mod *object.Module, program *ast.AST) object.Object {
+	l.loading = append(l.loading, mod)
+	var result object.Object
+	if errs := resolver.Resolve(program, globalsOf(mod)); len(errs) > 0 {
+		result = resolveError(mod.Path, errs)
+	} else if l.Engine != nil {
+		result = l.Engine.RunModule(mod, program)
+	} else {
+		result = Run(program, mod.Scope)
+	}
+	l.loading = l.loading[:len(l.loading)-1]
+
+	if err, ok := result.(*object.Error); ok {
+		err.In(mod.Path)
+	}
+	return result
+}
+
+// Import loads the module at path. A path starting with ./, ../ or / names a
+// .pc file, resolved relative to the directory of the importing module; any
+// other path names a native module from the standard library.
+func (l *Loader) Import(path string, from *object.Module) (*object.Module, *object.Error) {
+	if !isFilePath(path) {
+		return l.importNative(path)
+	}
+
+	if !filepath.IsAbs(path) && from != nil {
+		path = filepath.Join(filepath.Dir(from.Path), path)
+	}
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return nil, object.NewError("cannot import %s: %s", path, err)
+	}
+
+	for i, mod := range l.loading {
+		if abs, _ := filepath.Abs(mod.Path); abs == key {
+			chain := make([]string, 0, len(l.loading)-i+1)
+			for _, m := range l.loading[i:] {
+				chain = append(chain, m.Path)
+			}
+			chain = append(chain, path)
+			return nil, object.NewError("import cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	if mod, ok := l.modules[key]; ok {
+		return mod, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, object.NewError("cannot import %s: %s", path, err)
+	}
+	defer f.Close()
+
+	program, diagnostics := parser.NewParser(lexer.NewLexer(bufio.NewReader(f))).Parse()
+	if len(diagnostics) > 0 {
+		return nil, syntaxError(path, diagnostics)
+	}
+
+	mod, result := l.Exec(path, program)
+	if err, ok := result.(*object.Error); ok {
+		return nil, err
+	}
+	return mod, nil
+}
+
+// globalsOf describes the bindings a program run in mod can use without
+// declaring them: those left at its top level by earlier runs, those of the
+// prelude and the builtins.
+func globalsOf(mod *object.Module) resolver.Globals {
+	return func(name string) (bool, bool) {
+		if bind, ok := mod.Scope.Binding(name); ok {
+			return bind.Type == object.CONST, true
+		}
+		if _, ok := mod.Get(name); ok {
+			return false, true
+		}
+		if _, ok := LookupBuiltin(name); ok {
+			return true, true
+		}
+		return false, false
+	}
+}
+
+// loaderOf returns the loader of the module scope is in, if it has one.
+func loaderOf(scope *object.Scope) *Loader {
+	mod := scope.CurrentModule()
+	if mod == nil {
+		return nil
+	}
+	l, _ := mod.Importer.(*Loader)
+	return l
+}
+
+// resolveError reports the errors found resolving a program in the module
+// at path. The error is at the first of them, and its message lists the
+// rest on lines of their own, each prefixed with its path and position as
+// syntax errors are.
+func resolveError(path string, errs []*resolver.Error) *object.Error {
+	var msg strings.Builder
+	msg.WriteString(errs[0].Message)
+	for _, e := range errs[1:] {
+		msg.WriteString("\n")
+		if path != "" {
+			msg.WriteString(path + ":")
+		}
+		msg.WriteString(e.Error())
+	}
+	return &object.Error{Message: msg.String(), File: path, Pos: errs[0].Pos}
+}
+
+func isFilePath(path string) bool {
+	return strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") || filepath.IsAbs(path)
+}
+
+// importNative builds the module for a native module the first time it is
+// imported.
+func (l *Loader) importNative(name string) (*object.Module, *object.Error) {
+	if mod, ok := l.modules[name]; ok {
+		return mod, nil
+	}
+
+	native, ok := stdlib.Lookup(name)
+	if !ok {
+		return nil, object.NewError("unknown module %s", name)
+	}
+
+	scope := object.NewScope()
+	mod := &object.Module{Name: name, Path: name, Scope: scope, Exports: make(map[string]bool)}
+	scope.Module = mod
+	for fnName, fn := range native.Builtins {
+		scope.Set(fnName, &object.Builtin{Func: fn}, object.CONST)
+		mod.Exports[fnName] = true
+	}
+	for constName, val := range native.Constants {
+		scope.Set(constName, val, object.CONST)
+		mod.Exports[constName] = true
+	}
+
+	l.modules[name] = mod
+	return mod, nil
+}
+
+// syntaxError reports the first of the diagnostics of an imported file.
+func syntaxError(path string, diagnostics []parser.Diagnostic) *object.Error {
+	d := diagnostics[0]
+	msg := d.Message
+	if len(diagnostics) > 1 {
+		msg += fmt.Sprintf(" (and %d more errors)", len(diagnostics)-1)
+	}
+	return &object.Error{Message: msg, File: path, Pos: d.Start}
+}
+
+func runImportStatement(is *ast.ImportStatement, scope *object.Scope) object.Object {
+	from := scope.CurrentModule()
+	if from == nil || from.Importer == nil {
+		return object.NewError("cannot import %s: imports are not available here", is.Path.Value).At(is.Pos())
+	}
+
+	mod, err := from.Importer.Import(is.Path.Value, from)
+	if err != nil {
+		if err.File == "" {
+			return err.At(is.Pos()).In(from.Path)
+		}
+		err.Stack = append(err.Stack, object.Frame{Function: "import " + is.Path.Value, File: from.Path, Pos: is.Pos()})
+		return err
+	}
+
+	if is.Alias == nil {
+		if err := scope.Set(mod.Name, mod, object.CONST); err != nil {
+			return err.At(is.Path.Pos())
+		}
+		return &object.Null{}
+	}
+	if err := scope.Set(is.Alias.Value, mod, object.CONST); err != nil {
+		return err.At(is.Alias.Pos())
+	}
+	return &object.Null{}
+}
+
+func runExportStatement(es *ast.ExportStatement, scope *object.Scope) object.Object {
+	result := Run(es.Statement, scope)
+	if isUnwinding(result) {
+		return result
+	}
+
+	mod := scope.CurrentModule()
+	if mod == nil {
+		return object.NewError("`export` outside of a module").At(es.Pos())
+	}
+	mod.Exports[exportedName(es.Statement)] = true
+	return result
+}
+
+func exportedName(stmt ast.Statement) string {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Name.Value
+	case *ast.ConstStatement:
+		return stmt.Name.Value
+	case *ast.StructStatement:
+		return stmt.Name.Value
+	case *ast.ExpressionStatement:
+		return stmt.Expression.(*ast.FunctionLiteral).Name.Value
+	default:
+		return ""
+	}
+}
+
+// fileOf returns the path of the module scope belongs to, if any.
+func fileOf(scope *object.Scope) string {
+	if mod := scope.CurrentModule(); mod != nil {
+		return mod.Path
+	}
+	return ""
+}
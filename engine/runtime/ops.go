@@ -0,0 +1,88 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package runtime
+
+import (
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+// The functions in this file give other engines, such as the bytecode vm,
+// the semantics of Ponic's values and operators, so that a program behaves
+// the same whichever engine runs it. Errors they return carry no position.
+
+// BinaryOp applies an arithmetic or comparison operator. && and || are
+// not included; they short-circuit and must be compiled as jumps.
+func BinaryOp(operator string, left, right object.Object) object.Object {
+	return runBinop(operator, left, right)
+}
+
+// UnaryOp applies the prefix operator ! or -.
+func UnaryOp(operator string, right object.Object) object.Object {
+	return runUnop(operator, right)
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+func Index(left, index object.Object) object.Object {
+	return runIndexExpression(left, index)
+}
+
+// SetIndex assigns val to index of container with the assignment operator
+// operator, one of = += -= *= /= %=.
+func SetIndex(container, index object.Object, operator string, val object.Object) object.Object {
+	return assignIndex(container, index, operator, val)
+}
+
+func Member(obj object.Object, property string) object.Object {
+	return runMemberExpression(obj, property)
+}
+
+// SetMember assigns val to the field property of obj with the assignment
+// operator operator.
+func SetMember(obj object.Object, property string, operator string, val object.Object) object.Object {
+	return assignMember(obj, property, operator, val)
+}
+
+func HashKey(key object.Object) (object.Hashable, *object.Error) {
+	return hashKey(key)
+}
+
+// Iterate returns an iterator over the values a for-in loop visits in obj.
+func Iterate(obj object.Object) (object.Iterator, *object.Error) {
+	return iterate(obj)
+}
+
+// Call calls a function, builtin, bound method or struct type. It reports
+// false if fn cannot be called.
+func Call(fn object.Object, args []object.Object) (object.Object, bool) {
+	return call(fn, args)
+}
+
+// DeclareMethod attaches method, a function of params parameters, to the
+// struct receiver as name.
+func DeclareMethod(receiver object.Object, name string, method object.Object, params int) *object.Error {
+	return declareMethod(receiver, name, method, params)
+}
+
+// Throw returns the error that a throw statement raises for val.
+func Throw(val object.Object) *object.Error {
+	return thrown(val)
+}
+
+// CaughtValue returns what a catch clause that catches err binds.
+func CaughtValue(err *object.Error) object.Object {
+	return caughtValue(err)
+}
+
+// LookupBuiltin returns the builtin function called name.
+func LookupBuiltin(name string) (object.Object, bool) {
+	if fn, ok := Builtins[name]; ok {
+		return &object.Builtin{Func: fn}, true
+	}
+	return nil, false
+}
@@ -5,10 +5,25 @@
 package runtime
 
 import (
+	"math"
+	"strings"
+
 	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/lexer"
 	"github.com/danecwalker/ponic/engine/object"
 )
 
+const (
+	// MaxCallDepth is how many calls may be in progress at once before a
+	// call fails with a stack overflow.
+	MaxCallDepth = 1 << 14
+	// MaxTraceFrames is how many of the innermost and of the outermost
+	// calls the stack trace of an error keeps.
+	MaxTraceFrames = 10
+)
+
+// Run evaluates node in scope. The identifiers in node must have been
+// resolved by package resolver for scope; Loader.Run takes care of that.
 func Run(node ast.Node, scope *object.Scope) object.Object {
 	switch node := (node).(type) {
 	case *ast.AST:
@@ -17,65 +32,129 @@ func Run(node ast.Node, scope *object.Scope) object.Object {
 		return Run(node.Expression, scope)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.BooleanLiteral:
 		return &object.Boolean{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.ArrayLiteral:
+		elements, err := runExpressions(node.Elements, scope)
+		if err != nil {
+			return err
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return runHashLiteral(node, scope)
+	case *ast.IndexExpression:
+		left := Run(node.Left, scope)
+		if isError(left) {
+			return left
+		}
+		index := Run(node.Index, scope)
+		if isError(index) {
+			return index
+		}
+		return withPos(runIndexExpression(left, index), node.Pos())
+	case *ast.MemberExpression:
+		obj := Run(node.Object, scope)
+		if isError(obj) {
+			return obj
+		}
+		return withPos(runMemberExpression(obj, node.Property.Value), node.Pos())
+	case *ast.StructStatement:
+		return runStructStatement(node, scope)
+	case *ast.ImportStatement:
+		return runImportStatement(node, scope)
+	case *ast.ExportStatement:
+		return runExportStatement(node, scope)
 	case *ast.LetStatement:
 		val := Run(node.Value, scope)
-		scope.Set(node.Name.Value, val, object.LET)
+		if isUnwinding(val) {
+			return val
+		}
+		if err := declare(node.Name, val, object.LET, scope); err != nil {
+			return err.At(node.Name.Pos())
+		}
 	case *ast.ConstStatement:
 		val := Run(node.Value, scope)
-		scope.Set(node.Name.Value, val, object.CONST)
+		if isUnwinding(val) {
+			return val
+		}
+		if err := declare(node.Name, val, object.CONST, scope); err != nil {
+			return err.At(node.Name.Pos())
+		}
 	case *ast.ForExpression:
 		return runForExpression(node, scope)
+	case *ast.ForInExpression:
+		return runForInExpression(node, scope)
+	case *ast.ThrowStatement:
+		return runThrowStatement(node, scope)
+	case *ast.TryExpression:
+		return runTryExpression(node, scope)
+	case *ast.BreakStatement:
+		return &object.Break{}
+	case *ast.ContinueStatement:
+		return &object.Continue{}
+	case *ast.YieldStatement:
+		return runYieldStatement(node, scope)
 	case *ast.ReturnStatement:
 		val := Run(node.ReturnValue, scope)
+		if isError(val) {
+			return val
+		}
 		return &object.ReturnValue{Value: val}
 	case *ast.Identifier:
-		val, ok := scope.Get(node.Value)
-		if !ok {
-			if findBuiltin, ok := Builtins[node.Value]; ok {
-				return &object.Builtin{Func: findBuiltin}
-			}
-			panic("Undefined variable " + node.Value)
+		val, err := lookup(node, scope)
+		if err != nil {
+			return err
+		}
+		if val != nil {
+			return val
 		}
-		return val
+		if builtin, ok := LookupBuiltin(node.Value); ok {
+			return builtin
+		}
+		return undefined(node)
 	case *ast.UnOp:
 		right := Run(node.Right, scope)
-		return runUnop(node.Operator, right)
+		if isUnwinding(right) {
+			return right
+		}
+		return withPos(runUnop(node.Operator, right), node.Pos())
 	case *ast.BinOp:
 		if node.Operator == "=" || node.Operator == "+=" || node.Operator == "-=" || node.Operator == "*=" || node.Operator == "/=" || node.Operator == "%=" {
 			switch n := node.Left.(type) {
 			case *ast.Identifier:
-				return runRebind(n, node.Right, node.Operator, scope)
+				return withPos(runRebind(n, node.Right, node.Operator, scope), node.Pos())
+			case *ast.IndexExpression:
+				return withPos(runIndexAssign(n, node.Right, node.Operator, scope), node.Pos())
+			case *ast.MemberExpression:
+				return withPos(runMemberAssign(n, node.Right, node.Operator, scope), node.Pos())
+			default:
+				return object.NewError("cannot assign to %s", node.Left).At(node.Pos())
 			}
 		}
 		left := Run(node.Left, scope)
+		if isUnwinding(left) {
+			return left
+		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			return runLogicalOp(node.Operator, left, node.Right, scope)
+		}
 		right := Run(node.Right, scope)
-		return runBinop(node.Operator, left, right)
+		if isUnwinding(right) {
+			return right
+		}
+		return withPos(runBinop(node.Operator, left, right), node.Pos())
 	case *ast.IfExpression:
 		return runIfExpression(node, scope)
 	case *ast.BlockStatement:
-		return runBlockStatement(node, scope)
+		return runBlockStatement(node, object.NewEnclosedScope(scope))
 	case *ast.FunctionLiteral:
-		s := object.NewScope()
-		s.Parent = scope
-		if node.Named {
-			scope.Set(node.Name.Value, &object.Function{Parameters: node.Parameters, Body: node.Body, Scope: s}, object.FUNC)
-		} else {
-			return &object.Function{Parameters: node.Parameters, Body: node.Body, Scope: s}
-		}
+		return runFunctionLiteral(node, scope)
 	case *ast.CallExpression:
-		function := Run(node.Function, scope)
-		args := runExpressions(node.Arguments, scope)
-
-		switch function := function.(type) {
-		case *object.Function:
-			return applyFunction(function, args)
-		case *object.Builtin:
-			return function.Func(args...)
-		}
+		return runCallExpression(node, scope)
 	default:
 		return &object.Null{}
 	}
@@ -83,57 +162,45 @@ func Run(node ast.Node, scope *object.Scope) object.Object {
 	return &object.Null{}
 }
 
+// runRebind assigns to the variable left in the scope that declares it,
+// which may enclose the current one.
 func runRebind(left *ast.Identifier, right ast.Expression, operator string, scope *object.Scope) object.Object {
 	rightVal := Run(right, scope)
-	leftVal, ok := scope.Get(left.Value)
-	if !ok {
-		panic("Undefined variable " + left.Value)
+	if isError(rightVal) {
+		return rightVal
 	}
 
-	if operator == "=" {
-		scope.Set(left.Value, rightVal, object.LET)
-		return &object.Null{}
-	}
-
-	switch leftVal := leftVal.(type) {
-	case *object.Integer:
-		switch rightVal := rightVal.(type) {
-		case *object.Integer:
-			switch operator {
-			case "+=":
-				scope.Set(left.Value, &object.Integer{Value: leftVal.Value + rightVal.Value}, object.LET)
-				return &object.Null{}
-			case "-=":
-				scope.Set(left.Value, &object.Integer{Value: leftVal.Value - rightVal.Value}, object.LET)
-				return &object.Null{}
-			case "*=":
-				scope.Set(left.Value, &object.Integer{Value: leftVal.Value * rightVal.Value}, object.LET)
-				return &object.Null{}
-			case "/=":
-				scope.Set(left.Value, &object.Integer{Value: leftVal.Value / rightVal.Value}, object.LET)
-				return &object.Null{}
-			case "%=":
-				scope.Set(left.Value, &object.Integer{Value: leftVal.Value % rightVal.Value}, object.LET)
-				return &object.Null{}
-			}
+	if operator != "=" {
+		leftVal, err := lookup(left, scope)
+		if err != nil {
+			return err
 		}
-	case *object.String:
-		switch rightVal := rightVal.(type) {
-		case *object.String:
-			switch operator {
-			case "+=":
-				scope.Set(left.Value, &object.String{Value: leftVal.Value + rightVal.Value}, object.LET)
-				return &object.Null{}
-			}
+		if leftVal == nil {
+			return undefined(left)
+		}
+		rightVal = runBinop(strings.TrimSuffix(operator, "="), leftVal, rightVal)
+		if isError(rightVal) {
+			return rightVal
 		}
 	}
+
+	if err := assign(left, rightVal, scope); err != nil {
+		return err.At(left.Pos())
+	}
 	return &object.Null{}
 }
 
+// runAST runs the statements of a script. A top-level return ends the
+// script early; its *object.ReturnValue is returned as is so that callers
+// can tell it apart from the value of the last statement.
 func runAST(statements []ast.Statement, scope *object.Scope) object.Object {
 	var result object.Object
 	for _, statement := range statements {
 		result = Run(statement, scope)
+		switch result.(type) {
+		case *object.Error, *object.ReturnValue:
+			return result
+		}
 	}
 
 	if result == nil {
@@ -142,10 +209,16 @@ func runAST(statements []ast.Statement, scope *object.Scope) object.Object {
 	return result
 }
 
+// runBlockStatement runs the statements of block directly in scope. Run
+// gives a block a scope of its own; the body of a function, a for-in loop
+// or a catch clause shares the scope holding its parameters instead.
 func runBlockStatement(block *ast.BlockStatement, scope *object.Scope) object.Object {
 	var result object.Object
 	for _, statement := range block.Statements {
 		result = Run(statement, scope)
+		if isUnwinding(result) {
+			return result
+		}
 	}
 
 	if result == nil {
@@ -154,35 +227,159 @@ func runBlockStatement(block *ast.BlockStatement, scope *object.Scope) object.Ob
 	return result
 }
 
-func runExpressions(exps []ast.Expression, scope *object.Scope) []object.Object {
+// runFunctionLiteral creates a function value. A function closes over the
+// scope it is created in, not a copy of it: it sees the variables of that
+// scope as they are when it runs, and its assignments to them are seen by
+// everything else that shares the scope. Each call runs the body in a new
+// scope holding the parameters, so each function returned by a call of
+// an outer function has variables of its own.
+//
+// A named function is declared in the scope it is created in like a let.
+// The resolver lets any function in a scope refer to any variable of the
+// scope, so named functions can call themselves and each other whatever
+// order they are declared in.
+func runFunctionLiteral(fl *ast.FunctionLiteral, scope *object.Scope) object.Object {
+	fn := &object.Function{Parameters: fl.Parameters, Body: fl.Body, Scope: scope, Generator: fl.Generator}
+	if fl.Receiver != nil {
+		return runMethodDeclaration(fl, fn, scope)
+	}
+	if !fl.Named {
+		return fn
+	}
+
+	if err := declare(fl.Name, fn, object.FUNC, scope); err != nil {
+		return err.At(fl.Name.Pos())
+	}
+	return &object.Null{}
+}
+
+func runExpressions(exps []ast.Expression, scope *object.Scope) ([]object.Object, *object.Error) {
 	var result []object.Object
 	for _, e := range exps {
 		evaluated := Run(e, scope)
+		if err, ok := evaluated.(*object.Error); ok {
+			return nil, err
+		}
 		result = append(result, evaluated)
 	}
+	return result, nil
+}
+
+func runCallExpression(node *ast.CallExpression, scope *object.Scope) object.Object {
+	function := Run(node.Function, scope)
+	if isError(function) {
+		return function
+	}
+	args, err := runExpressions(node.Arguments, scope)
+	if err != nil {
+		return err
+	}
+
+	result, ok := call(function, args)
+	if !ok {
+		return object.NewError("%s is not a function", function.Inspect()).At(node.Pos())
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		file := fileOf(scope)
+		err.At(node.Pos()).In(file)
+		err.Stack = append(err.Stack, object.Frame{Function: calleeName(node.Function), File: file, Pos: node.Pos()})
+		err.Elide(MaxTraceFrames)
+	}
 	return result
 }
 
+// call calls fn with args. It reports false if fn cannot be called.
+func call(fn object.Object, args []object.Object) (object.Object, bool) {
+	switch fn := fn.(type) {
+	case *object.Function, *object.BoundMethod:
+		return applyFunction(fn, args), true
+	case *object.Builtin:
+		return fn.Func(args...), true
+	case *object.StructType:
+		return newInstance(fn, args), true
+	default:
+		return nil, false
+	}
+}
+
+func calleeName(node ast.Expression) string {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		return node.Value
+	case *ast.MemberExpression:
+		return calleeName(node.Object) + "." + node.Property.Value
+	default:
+		return "<anonymous>"
+	}
+}
+
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedScope := extendFunctionScope(fn, args)
-		evaluated := Run(fn.Body, extendedScope)
+		if len(args) != len(fn.Parameters) {
+			return object.NewError("wrong number of arguments: expected %d, got %d", len(fn.Parameters), len(args))
+		}
+		extendedScope, err := extendFunctionScope(fn, args)
+		if err != nil {
+			return err
+		}
+		if fn.Generator {
+			return newGenerator(fn, extendedScope)
+		}
+		if l := loaderOf(fn.Scope); l != nil {
+			if l.depth == MaxCallDepth {
+				return object.NewError("stack overflow")
+			}
+			l.depth++
+			defer func() { l.depth-- }()
+		}
+		evaluated := runBlockStatement(fn.Body, extendedScope)
+		if err, ok := evaluated.(*object.Error); ok {
+			err.In(fileOf(fn.Scope))
+		}
 		return unwrapReturnValue(evaluated)
+	case *object.BoundMethod:
+		return applyFunction(fn.Method, append([]object.Object{fn.Receiver}, args...))
 	default:
 		return &object.Null{}
 	}
 }
 
-func extendFunctionScope(fn *object.Function, args []object.Object) *object.Scope {
-	scope := object.NewScope()
-	scope.Parent = fn.Scope
+func extendFunctionScope(fn *object.Function, args []object.Object) (*object.Scope, *object.Error) {
+	scope := object.NewEnclosedScope(fn.Scope)
 
 	for paramIdx, param := range fn.Parameters {
-		scope.Set(param.Value, args[paramIdx], object.LET)
+		if err := declare(param, args[paramIdx], object.LET, scope); err != nil {
+			return nil, err
+		}
+	}
+
+	return scope, nil
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR
+}
+
+// isUnwinding reports whether obj stops the execution of enclosing blocks:
+// an error, a return value, a break or continue signal, or the signal that
+// closes a generator.
+func isUnwinding(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Error, *object.ReturnValue, *object.Break, *object.Continue, *generatorClosed:
+		return true
+	default:
+		return false
 	}
+}
 
-	return scope
+// withPos attaches pos to obj if it is an error that has no position yet.
+func withPos(obj object.Object, pos lexer.Position) object.Object {
+	if err, ok := obj.(*object.Error); ok {
+		err.At(pos)
+	}
+	return obj
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -205,31 +402,61 @@ func runUnop(operator string, right object.Object) object.Object {
 }
 
 func runBangOp(right object.Object) object.Object {
-	switch right {
-	case &object.Boolean{Value: true}:
-		return &object.Boolean{Value: false}
-	case &object.Boolean{Value: false}:
-		return &object.Boolean{Value: true}
-	case &object.Null{}:
+	switch right := right.(type) {
+	case *object.Boolean:
+		return &object.Boolean{Value: !right.Value}
+	case *object.Null:
 		return &object.Boolean{Value: true}
 	default:
 		return &object.Boolean{Value: false}
 	}
 }
 
+// runLogicalOp short-circuits && and ||: right is only evaluated when left
+// does not already decide the result, and the deciding operand is returned
+// as is rather than converted to a boolean.
+func runLogicalOp(operator string, left object.Object, right ast.Expression, scope *object.Scope) object.Object {
+	if operator == "&&" && !isTruthy(left) {
+		return left
+	}
+	if operator == "||" && isTruthy(left) {
+		return left
+	}
+	return Run(right, scope)
+}
+
 func runMinusOp(right object.Object) object.Object {
-	if right.Type() != object.INTEGER {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return &object.Null{}
 	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER || obj.Type() == object.FLOAT
+}
 
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
 }
 
 func runBinop(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER && right.Type() == object.INTEGER:
 		return runIntegerBinop(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return runFloatBinop(operator, toFloat(left), toFloat(right))
 	case left.Type() == object.STRING && right.Type() == object.STRING:
 		return runStringBinop(operator, left, right)
 	case left.Type() == object.BOOLEAN && right.Type() == object.BOOLEAN:
@@ -250,8 +477,14 @@ func runIntegerBinop(operator string, left, right object.Object) object.Object {
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return object.NewError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "%":
+		if rightVal == 0 {
+			return object.NewError("division by zero")
+		}
 		return &object.Integer{Value: leftVal % rightVal}
 	case "==":
 		return &object.Boolean{Value: leftVal == rightVal}
@@ -270,6 +503,43 @@ func runIntegerBinop(operator string, left, right object.Object) object.Object {
 	}
 }
 
+// runFloatBinop handles arithmetic where at least one operand is a float;
+// integer operands are widened to float64 first.
+func runFloatBinop(operator string, leftVal, rightVal float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return object.NewError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return object.NewError("division by zero")
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "==":
+		return &object.Boolean{Value: leftVal == rightVal}
+	case "!=":
+		return &object.Boolean{Value: leftVal != rightVal}
+	case ">":
+		return &object.Boolean{Value: leftVal > rightVal}
+	case "<":
+		return &object.Boolean{Value: leftVal < rightVal}
+	case ">=":
+		return &object.Boolean{Value: leftVal >= rightVal}
+	case "<=":
+		return &object.Boolean{Value: leftVal <= rightVal}
+	default:
+		return &object.Null{}
+	}
+}
+
 func runStringBinop(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -302,6 +572,9 @@ func runBooleanBinop(operator string, left, right object.Object) object.Object {
 
 func runIfExpression(ie *ast.IfExpression, scope *object.Scope) object.Object {
 	condition := Run(ie.Condition, scope)
+	if isError(condition) {
+		return condition
+	}
 	if isTruthy(condition) {
 		return Run(ie.Consequence, scope)
 	} else if ie.Alternative != nil {
@@ -327,26 +600,88 @@ func isTruthy(obj object.Object) bool {
 }
 
 func runForExpression(fe *ast.ForExpression, s *object.Scope) object.Object {
-	scope := object.NewScope()
-	scope.Parent = s
+	scope := object.NewEnclosedScope(s)
 	var result object.Object
 	if !fe.ConditionOnly {
-		Run(fe.Initializer, scope)
+		if init := Run(fe.Initializer, scope); isError(init) {
+			return init
+		}
 	}
 	for {
 		condition := Run(fe.Condition, scope)
+		if isError(condition) {
+			return condition
+		}
 		if !isTruthy(condition) {
 			break
 		}
 
 		result = Run(fe.Body, scope)
-		if _, ok := result.(*object.ReturnValue); ok {
+		if _, ok := result.(*object.Break); ok {
 			break
 		}
+		if isUnwinding(result) {
+			if _, ok := result.(*object.Continue); !ok {
+				return result
+			}
+		}
 
 		if !fe.ConditionOnly {
-			Run(fe.Post, scope)
+			if post := Run(fe.Post, scope); isError(post) {
+				return post
+			}
 		}
 	}
 	return &object.Null{}
 }
+
+func runThrowStatement(ts *ast.ThrowStatement, scope *object.Scope) object.Object {
+	val := Run(ts.Value, scope)
+	if err, ok := val.(*object.Error); ok {
+		return err
+	}
+
+	return thrown(val).At(ts.Pos())
+}
+
+// thrown is the error that throwing val raises.
+func thrown(val object.Object) *object.Error {
+	return &object.Error{Message: val.Inspect(), Value: val}
+}
+
+func runTryExpression(te *ast.TryExpression, scope *object.Scope) object.Object {
+	result := Run(te.Block, scope)
+
+	if err, ok := result.(*object.Error); ok && te.Catch != nil {
+		catchScope := object.NewEnclosedScope(scope)
+		var paramErr *object.Error
+		if te.Param != nil {
+			paramErr = declare(te.Param, caughtValue(err), object.LET, catchScope)
+		}
+		if paramErr != nil {
+			result = paramErr
+		} else {
+			result = runBlockStatement(te.Catch, catchScope)
+		}
+	}
+
+	// a finally block that fails, returns, breaks or continues replaces the
+	// outcome of the rest of the try
+	if te.Finally != nil {
+		if final := Run(te.Finally, scope); isUnwinding(final) {
+			return final
+		}
+	}
+
+	return result
+}
+
+// caughtValue is what a catch clause binds: the value given to throw, or
+// the message of an error raised by the runtime. The error itself is never
+// bound, as an *object.Error value would keep unwinding wherever it was used.
+func caughtValue(err *object.Error) object.Object {
+	if err.Value != nil {
+		return err.Value
+	}
+	return &object.String{Value: err.Message}
+}
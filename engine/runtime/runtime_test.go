@@ -0,0 +1,655 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package runtime
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danecwalker/ponic/engine/internal/testutil"
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+// run runs src as the top level of a new module and returns the value of
+// its last statement.
+func run(t *testing.T, src string) object.Object {
+	t.Helper()
+	return runOn(t, nil, src)
+}
+
+// runOn is run on engine, or on the walker if engine is nil.
+func runOn(t *testing.T, engine Engine, src string) object.Object {
+	t.Helper()
+	l := NewLoader()
+	l.Engine = engine
+	return l.Run(l.NewModule(""), testutil.Parse(t, src))
+}
+
+// engines are the engines the closure, scope and try tests run on, which
+// must agree on how variables are scoped and captured and how errors
+// unwind. The vm is added by vm_test.go, as this package cannot import it.
+var engines = []testEngine{
+	{"walker", func() Engine { return nil }},
+}
+
+type testEngine struct {
+	name string
+	new  func() Engine
+}
+
+// AddTestEngine adds an engine for the closure, scope and try tests to run
+// on.
+func AddTestEngine(name string, new func() Engine) {
+	engines = append(engines, testEngine{name, new})
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "independent counters",
+			src: `
+				fn counter() {
+					let n = 0;
+					return fn() { n += 1; return n; };
+				}
+				let a = counter();
+				let b = counter();
+				a(); a();
+				b();
+				[a(), b()]`,
+			want: "[3, 2]",
+		},
+		{
+			name: "closures from one call share state",
+			src: `
+				fn account() {
+					let balance = 0;
+					return {
+						"deposit": fn(amount) { balance += amount; },
+						"balance": fn() { return balance; },
+					};
+				}
+				let acct = account();
+				acct["deposit"](10);
+				acct["deposit"](5);
+				acct["balance"]()`,
+			want: "15",
+		},
+		{
+			name: "captured variable assigned after closure creation",
+			src: `
+				fn make() {
+					let x = 1;
+					let get = fn() { return x; };
+					x = 2;
+					return get;
+				}
+				make()()`,
+			want: "2",
+		},
+		{
+			name: "lexical not dynamic scope",
+			src: `
+				let x = "global";
+				fn show() { return x; }
+				fn call() { let x = "local"; return show(); }
+				call() == "global"`,
+			want: "true",
+		},
+		{
+			name: "assignment updates enclosing global",
+			src: `
+				let total = 0;
+				fn add(n) { total += n; }
+				for (let i = 1; i <= 4; i += 1) { add(i); }
+				total`,
+			want: "10",
+		},
+		{
+			name: "for-in captures each iteration",
+			src: `
+				let fns = [];
+				for (v in [1, 2, 3]) { push(fns, fn() { return v; }); }
+				let got = [fns[0](), fns[1](), fns[2]()];
+				got`,
+			want: "[1, 2, 3]",
+		},
+		{
+			name: "recursive named function",
+			src: `
+				fn fact(n) { if (n <= 1) { return 1; } return n * fact(n - 1); }
+				fact(10)`,
+			want: "3628800",
+		},
+		{
+			name: "recursive function bound with let",
+			src: `
+				fn outer() {
+					let fib = fn(n) { if (n < 2) { return n; } return fib(n - 1) + fib(n - 2); };
+					return fib(15);
+				}
+				outer()`,
+			want: "610",
+		},
+		{
+			name: "mutual recursion at the top level",
+			src: `
+				fn isEven(n) { if (n == 0) { return true; } return isOdd(n - 1); }
+				fn isOdd(n) { if (n == 0) { return false; } return isEven(n - 1); }
+				let got = [isEven(10), isOdd(7), isEven(3)];
+				got`,
+			want: "[true, true, false]",
+		},
+		{
+			name: "mutual recursion in a function",
+			src: `
+				fn parity(n) {
+					fn isEven(n) { if (n == 0) { return true; } return isOdd(n - 1); }
+					fn isOdd(n) { if (n == 0) { return false; } return isEven(n - 1); }
+					return isEven(n);
+				}
+				let got = [parity(4), parity(5)];
+				got`,
+			want: "[true, false]",
+		},
+		{
+			name: "block variables shadow without leaking",
+			src: `
+				let x = 1;
+				if (true) { let x = 2; x += 1; }
+				x`,
+			want: "1",
+		},
+		{
+			name: "sibling blocks reuse a name",
+			src: `
+				let got = [];
+				if (true) { let y = 1; push(got, y); }
+				if (true) { let y = 2; push(got, y); }
+				got`,
+			want: "[1, 2]",
+		},
+		{
+			name: "inner functions declared after use",
+			src: `
+				fn f() {
+					fn first() { return second(); }
+					fn second() { return 2; }
+					return first();
+				}
+				f()`,
+			want: "2",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range engines {
+			t.Run(engine.name+"/"+tt.name, func(t *testing.T) {
+				got := runOn(t, engine.new(), tt.src)
+				if err, ok := got.(*object.Error); ok {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if got.Inspect() != tt.want {
+					t.Errorf("got %s, want %s", got.Inspect(), tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestScopeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "undefined variable",
+			src:  `fn f() { return missing; }`,
+			want: "1:17: Undefined variable missing",
+		},
+		{
+			name: "block variable used outside its block",
+			src: `
+if (true) { let y = 1; }
+y`,
+			want: "3:1: Undefined variable y",
+		},
+		{
+			name: "constant of an enclosing scope",
+			src: `
+const limit = 3;
+fn raise() { limit = 4; }`,
+			want: "3:14: Cannot reassign constant limit",
+		},
+		{
+			name: "redeclaration in the same scope",
+			src: `
+fn f(a) {
+	let b = 1;
+	let b = 2;
+}`,
+			want: "4:6: Cannot redeclare b",
+		},
+		{
+			name: "use before declaration runs",
+			src: `
+let x = 1;
+fn f() { let y = x; let x = 2; return y; }
+f()`,
+			want: "3:18: x used before its declaration",
+		},
+		{
+			name: "use in its own initializer",
+			src: `
+let x = 1;
+fn f() { let x = x + 1; return x; }`,
+			want: "3:18: x used before its declaration",
+		},
+		{
+			name: "assignment before declaration",
+			src: `
+fn f() {
+	total = 1;
+	let total = 0;
+}`,
+			want: "3:2: total used before its declaration",
+		},
+		{
+			name: "closure called before the declaration runs",
+			src: `
+fn f() {
+	let get = fn() { return x; };
+	get();
+	let x = 1;
+}
+f()`,
+			want: "3:26: x used before its declaration",
+		},
+		{
+			name: "parameter redeclared in the body",
+			src:  `fn f(a) { let a = 1; }`,
+			want: "1:15: Cannot redeclare a",
+		},
+		{
+			name: "else variable used outside its block",
+			src: `
+if (false) { } else { let z = 1; }
+z`,
+			want: "3:1: Undefined variable z",
+		},
+		{
+			name: "loop variable used after the loop",
+			src: `
+for (let i = 0; i < 2; i += 1) { }
+i`,
+			want: "3:1: Undefined variable i",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range engines {
+			t.Run(engine.name+"/"+tt.name, func(t *testing.T) {
+				got := runOn(t, engine.new(), tt.src)
+				err, ok := got.(*object.Error)
+				if !ok {
+					t.Fatalf("got %s, want error %q", got.Inspect(), tt.want)
+				}
+				if msg := strings.SplitN(err.Error(), "\n", 2)[0]; msg != tt.want {
+					t.Errorf("got error %q, want %q", msg, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestStringLength(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`len("hello")`, "5"},
+		{`len("héllo wörld")`, "11"},
+		{`len("日本語")`, "3"},
+		{`len("")`, "0"},
+		{`let s = "日本語"; let n = 0; for (c in s) { n += 1; } n == len(s)`, "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got := run(t, tt.src)
+			if got.Inspect() != tt.want {
+				t.Errorf("got %s, want %s", got.Inspect(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`true && false`, "false"},
+		{`false || true`, "true"},
+		{`let calls = 0; fn hit() { calls += 1; return true; } false && hit(); true || hit(); calls`, "0"},
+		{`let calls = 0; fn hit() { calls += 1; return true; } true && hit(); false || hit(); calls`, "2"},
+		{`false || 5`, "5"},
+		{`true && "right"`, "right"},
+		// only true is truthy, and ! negates booleans and null only
+		{`if (1) { "yes" } else { "no" }`, "no"},
+		{`if ("text") { "yes" } else { "no" }`, "no"},
+		{`1 && 2`, "1"},
+		{`[!true, !false, !fn() {}(), !0, !"text"]`, "[false, true, true, false, false]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got := run(t, tt.src)
+			if got.Inspect() != tt.want {
+				t.Errorf("got %s, want %s", got.Inspect(), tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	src := `
+let ran = true;
+fn f() { return missing; }
+const limit = 1;
+limit = 2;
+other`
+	l := NewLoader()
+	mod := l.NewModule("main.pc")
+	got := l.Run(mod, testutil.Parse(t, src))
+
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("got %s, want an error", got.Inspect())
+	}
+	want := `main.pc:3:17: Undefined variable missing
+main.pc:5:1: Cannot reassign constant limit
+main.pc:6:1: Undefined variable other`
+	if err.Error() != want {
+		t.Errorf("got error\n%s\nwant\n%s", err, want)
+	}
+	if _, ok := mod.Scope.Binding("ran"); ok {
+		t.Errorf("the program ran although it did not resolve")
+	}
+}
+
+// TestUnresolved runs trees that skipped the resolver, whose identifiers
+// must not read or write slot 0 of a scope.
+func TestUnresolved(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"use", "len", "1:1: len was not resolved"},
+		{"declaration", "let x = 1;", "1:5: x was not resolved"},
+		{"assignment", "[1][0] = 2; x = 2;", "1:13: x was not resolved"},
+		{"parameter", "fn(a) { return 1; }(2)", "1:4: a was not resolved\n    at <anonymous> (1:1)"},
+		{"loop variable", "for (v in [1]) {}", "1:6: v was not resolved"},
+		{"catch parameter", "try { throw 1; } catch (e) {}", "1:25: e was not resolved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Run(testutil.Parse(t, tt.src), NewLoader().NewModule("").Scope)
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("got %s, want an error", got.Inspect())
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got error %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "from a nested block",
+			src: `
+				fn f() {
+					let x = 1;
+					if (true) { x = 2; }
+					return x;
+				}
+				f()`,
+			want: "2",
+		},
+		{
+			name: "from a loop body",
+			src: `
+				let last = 0;
+				for (v in [1, 2, 3]) { last = v; }
+				last`,
+			want: "3",
+		},
+		{
+			name: "through two closures",
+			src: `
+				fn outer() {
+					let n = 0;
+					fn middle() { return fn() { n *= 2; n += 1; }; }
+					let inc = middle();
+					inc(); inc(); inc();
+					return n;
+				}
+				outer()`,
+			want: "7",
+		},
+		{
+			name: "a global from a function",
+			src: `
+				let count = 0;
+				fn bump() { count = count + 1; }
+				bump(); bump();
+				count`,
+			want: "2",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range engines {
+			t.Run(engine.name+"/"+tt.name, func(t *testing.T) {
+				got := runOn(t, engine.new(), tt.src)
+				if err, ok := got.(*object.Error); ok {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if got.Inspect() != tt.want {
+					t.Errorf("got %s, want %s", got.Inspect(), tt.want)
+				}
+			})
+		}
+	}
+}
+
+// TestAssignPrelude checks that a program assigns to a variable of the
+// prelude in the prelude, rather than declaring one of its own.
+func TestAssignPrelude(t *testing.T) {
+	prelude := object.NewScope()
+	prelude.Set("setting", &object.Integer{Value: 1}, object.LET)
+	prelude.Set("fixed", &object.Integer{Value: 1}, object.CONST)
+	l := NewLoader()
+	l.Prelude = prelude
+
+	mod := l.NewModule("")
+	if got := l.Run(mod, testutil.Parse(t, `setting = 2;`)); isError(got) {
+		t.Fatalf("unexpected error: %s", got.(*object.Error))
+	}
+	if _, ok := mod.Scope.Values["setting"]; ok {
+		t.Errorf("declared setting in the module")
+	}
+	if got, _ := prelude.Get("setting"); got.Inspect() != "2" {
+		t.Errorf("got setting = %s in the prelude, want 2", got.Inspect())
+	}
+
+	got := l.Run(l.NewModule(""), testutil.Parse(t, `fixed = 2;`))
+	if err, ok := got.(*object.Error); !ok || err.Message != "Cannot reassign constant fixed" {
+		t.Errorf("got %s, want an error reassigning fixed", got.Inspect())
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	l := NewLoader()
+	got := l.Run(l.NewModule(""), testutil.Parse(t, `
+fn down(n) { return down(n + 1); }
+down(0)`))
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("got %s, want an error", got.Inspect())
+	}
+	if msg, want := strings.SplitN(err.Error(), "\n", 2)[0], "2:21: stack overflow"; msg != want {
+		t.Errorf("got error %q, want %q", msg, want)
+	}
+	if n := len(err.Stack); n != 2*MaxTraceFrames {
+		t.Errorf("got a trace of %d frames, want %d", n, 2*MaxTraceFrames)
+	}
+
+	// the calls are released as the error unwinds them
+	got = l.Run(l.NewModule(""), testutil.Parse(t, `
+fn down(n) { if (n == 0) { return 0; } return down(n - 1); }
+down(1000)`))
+	if got.Inspect() != "0" {
+		t.Errorf("got %s after a stack overflow, want 0", got.Inspect())
+	}
+}
+
+func TestTry(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "value of a block that does not fail",
+			src:  `try { 1 + 1 } catch (e) { 0 }`,
+			want: "2",
+		},
+		{
+			name: "catch a thrown value",
+			src:  `try { throw {"code": 7}; } catch (e) { e["code"] }`,
+			want: "7",
+		},
+		{
+			name: "catch a runtime error",
+			src:  `try { [][1] } catch (e) { e }`,
+			want: "array index 1 out of range with length 0",
+		},
+		{
+			name: "catch without a parameter",
+			src:  `try { 1 / 0 } catch { "failed" }`,
+			want: "failed",
+		},
+		{
+			name: "throw from a called function",
+			src: `
+				fn check(n) { if (n < 0) { throw "negative"; } return n; }
+				let got = [try { check(1) } catch (e) { e }, try { check(-1) } catch (e) { e }];
+				got`,
+			want: `[1, "negative"]`,
+		},
+		{
+			name: "finally runs on return",
+			src: `
+				let log = [];
+				fn f() {
+					try { return "body"; } finally { push(log, "finally"); }
+				}
+				let got = [f(), log];
+				got`,
+			want: `["body", ["finally"]]`,
+		},
+		{
+			name: "finally runs on break",
+			src: `
+				let log = [];
+				for (i in range(5)) {
+					try { if (i == 1) { break; } } finally { push(log, i); }
+				}
+				log`,
+			want: "[0, 1]",
+		},
+		{
+			name: "finally runs on throw",
+			src: `
+				let log = [];
+				let caught = try {
+					try { throw "inner"; } finally { push(log, "finally"); }
+				} catch (e) { e };
+				let got = [caught, log];
+				got`,
+			want: `["inner", ["finally"]]`,
+		},
+		{
+			name: "return in finally replaces the result",
+			src: `
+				fn f() { try { return 1; } finally { return 2; } }
+				f()`,
+			want: "2",
+		},
+		{
+			name: "rethrow from catch",
+			src: `
+				try {
+					try { throw "first"; } catch (e) { throw e + " again"; }
+				} catch (e) { e }`,
+			want: "first again",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range engines {
+			t.Run(engine.name+"/"+tt.name, func(t *testing.T) {
+				got := runOn(t, engine.new(), tt.src)
+				if err, ok := got.(*object.Error); ok {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if got.Inspect() != tt.want {
+					t.Errorf("got %s, want %s", got.Inspect(), tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestUncaughtThrow(t *testing.T) {
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			got := runOn(t, engine.new(), `
+fn thrower() { throw {"code": 7}; }
+fn outer() { thrower(); }
+outer()`)
+
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("got %s, want an error", got.Inspect())
+			}
+			want := `2:16: {"code": 7}
+    at thrower (3:14)
+    at outer (4:1)`
+			if err.Error() != want {
+				t.Errorf("got error\n%s\nwant\n%s", err, want)
+			}
+			if err.Value == nil || err.Value.Inspect() != `{"code": 7}` {
+				t.Errorf("got thrown value %v, want the hash", err.Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package runtime
+
+import (
+	"strings"
+
+	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+func runStructStatement(ss *ast.StructStatement, scope *object.Scope) object.Object {
+	st := &object.StructType{Name: ss.Name.Value, Methods: map[string]object.Object{}}
+	seen := map[string]bool{}
+	for _, field := range ss.Fields {
+		if seen[field.Value] {
+			return object.NewError("duplicate field %s in struct %s", field.Value, st.Name).At(field.Pos())
+		}
+		seen[field.Value] = true
+		st.Fields = append(st.Fields, field.Value)
+	}
+
+	if err := declare(ss.Name, st, object.CONST, scope); err != nil {
+		return err.At(ss.Name.Pos())
+	}
+	return &object.Null{}
+}
+
+// runMethodDeclaration attaches fn to the struct named by the receiver of
+// fl. Methods are not bound in scope; they are reached through instances.
+func runMethodDeclaration(fl *ast.FunctionLiteral, fn *object.Function, scope *object.Scope) object.Object {
+	receiver, err := lookup(fl.Receiver, scope)
+	if err != nil {
+		return err
+	}
+	if receiver == nil {
+		return undefined(fl.Receiver)
+	}
+	if err := declareMethod(receiver, fl.Name.Value, fn, len(fl.Parameters)); err != nil {
+		return err.At(fl.Name.Pos())
+	}
+	return &object.Null{}
+}
+
+// declareMethod attaches method, which takes params parameters, to the
+// struct receiver as name.
+func declareMethod(receiver object.Object, name string, method object.Object, params int) *object.Error {
+	st, ok := receiver.(*object.StructType)
+	if !ok {
+		return object.NewError("cannot declare method on %s", receiver.Inspect())
+	}
+	if params == 0 {
+		return object.NewError("method %s.%s must take a receiver parameter", st.Name, name)
+	}
+	if hasField(st, name) {
+		return object.NewError("method %s.%s conflicts with a field", st.Name, name)
+	}
+
+	st.Methods[name] = method
+	return nil
+}
+
+func newInstance(st *object.StructType, args []object.Object) object.Object {
+	if len(args) != len(st.Fields) {
+		return object.NewError("wrong number of fields for %s: expected %d, got %d", st.Name, len(st.Fields), len(args))
+	}
+
+	inst := &object.Instance{Struct: st, Fields: make(map[string]object.Object, len(args))}
+	for i, name := range st.Fields {
+		inst.Fields[name] = args[i]
+	}
+	return inst
+}
+
+func hasField(st *object.StructType, name string) bool {
+	for _, field := range st.Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+func runMemberExpression(obj object.Object, property string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Instance:
+		if val, ok := obj.Fields[property]; ok {
+			return val
+		}
+		if method, ok := obj.Struct.Methods[property]; ok {
+			return &object.BoundMethod{Receiver: obj, Method: method}
+		}
+		return object.NewError("%s has no field or method %s", obj.Struct.Name, property)
+	case *object.StructType:
+		if method, ok := obj.Methods[property]; ok {
+			return method
+		}
+		return object.NewError("%s has no method %s", obj.Name, property)
+	case *object.Module:
+		if val, ok := obj.Export(property); ok {
+			return val
+		}
+		return object.NewError("module %s has no exported member %s", obj.Name, property)
+	default:
+		return object.NewError("member access not supported on %s", obj.Inspect())
+	}
+}
+
+func runMemberAssign(left *ast.MemberExpression, right ast.Expression, operator string, scope *object.Scope) object.Object {
+	obj := Run(left.Object, scope)
+	if isError(obj) {
+		return obj
+	}
+	val := Run(right, scope)
+	if isError(val) {
+		return val
+	}
+
+	return assignMember(obj, left.Property.Value, operator, val)
+}
+
+// assignMember stores val in the field property of obj, first combining it
+// with the current value for a compound operator such as +=.
+func assignMember(obj object.Object, property string, operator string, val object.Object) object.Object {
+	inst, ok := obj.(*object.Instance)
+	if !ok {
+		return object.NewError("member assignment not supported on %s", obj.Inspect())
+	}
+	current, ok := inst.Fields[property]
+	if !ok {
+		return object.NewError("%s has no field %s", inst.Struct.Name, property)
+	}
+
+	if operator != "=" {
+		val = runBinop(strings.TrimSuffix(operator, "="), current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	inst.Fields[property] = val
+	return &object.Null{}
+}
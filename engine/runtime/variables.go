@@ -0,0 +1,68 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package runtime
+
+import (
+	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+// The functions in this file access variables through identifiers set up
+// by the resolver: locals by slot, globals by name. They fail on an
+// identifier the resolver has not bound.
+
+// lookup returns the value of the variable ident refers to, or nil if it
+// has not been declared yet.
+func lookup(ident *ast.Identifier, scope *object.Scope) (object.Object, *object.Error) {
+	switch ident.Depth {
+	case ast.Unresolved:
+		return nil, unresolved(ident)
+	case ast.Global:
+		val, _ := scope.Get(ident.Value)
+		return val, nil
+	}
+	val, _ := scope.GetSlot(ident.Depth, ident.Slot)
+	return val, nil
+}
+
+// declare binds the variable ident declares in scope.
+func declare(ident *ast.Identifier, val object.Object, bindType object.BindType, scope *object.Scope) *object.Error {
+	switch ident.Depth {
+	case ast.Unresolved:
+		return unresolved(ident)
+	case ast.Global:
+		return scope.Set(ident.Value, val, bindType)
+	}
+	scope.SetSlot(ident.Slot, val, bindType)
+	return nil
+}
+
+// assign assigns val to the variable ident refers to, in the scope that
+// declares it.
+func assign(ident *ast.Identifier, val object.Object, scope *object.Scope) *object.Error {
+	switch ident.Depth {
+	case ast.Unresolved:
+		return unresolved(ident)
+	case ast.Global:
+		return scope.Assign(ident.Value, val)
+	}
+	return scope.AssignSlot(ident.Depth, ident.Slot, ident.Value, val)
+}
+
+// undefined is the error for a use of ident that finds no value. The
+// resolver has checked that a local is declared in its scope, so the
+// declaration has not run yet.
+func undefined(ident *ast.Identifier) *object.Error {
+	if ident.Depth == ast.Global {
+		return object.NewError("Undefined variable %s", ident.Value).At(ident.Pos())
+	}
+	return object.NewError("%s used before its declaration", ident.Value).At(ident.Pos())
+}
+
+// unresolved is the error for running ident before the resolver bound it.
+func unresolved(ident *ast.Identifier) *object.Error {
+	return object.NewError("%s was not resolved", ident.Value).At(ident.Pos())
+}
@@ -0,0 +1,15 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package runtime_test
+
+import (
+	"github.com/danecwalker/ponic/engine/runtime"
+	"github.com/danecwalker/ponic/engine/vm"
+)
+
+func init() {
+	runtime.AddTestEngine("vm", func() runtime.Engine { return vm.New() })
+}
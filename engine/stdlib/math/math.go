@@ -0,0 +1,143 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package math is the native math module.
+package math
+
+import (
+	gomath "math"
+
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+var Builtins = map[string]object.BuiltinFunction{
+	"abs":   _abs,
+	"min":   _min,
+	"max":   _max,
+	"floor": rounding("floor", gomath.Floor),
+	"ceil":  rounding("ceil", gomath.Ceil),
+	"round": rounding("round", gomath.Round),
+	"sqrt":  unary("sqrt", gomath.Sqrt),
+	"sin":   unary("sin", gomath.Sin),
+	"cos":   unary("cos", gomath.Cos),
+	"tan":   unary("tan", gomath.Tan),
+	"log":   unary("log", gomath.Log),
+	"pow":   _pow,
+}
+
+var Constants = map[string]object.Object{
+	"pi":  &object.Float{Value: gomath.Pi},
+	"e":   &object.Float{Value: gomath.E},
+	"inf": &object.Float{Value: gomath.Inf(1)},
+}
+
+func number(name string, arg object.Object) (float64, *object.Error) {
+	switch arg := arg.(type) {
+	case *object.Integer:
+		return float64(arg.Value), nil
+	case *object.Float:
+		return arg.Value, nil
+	default:
+		return 0, object.NewError("argument to `%s` must be a number, got %s", name, arg.Inspect())
+	}
+}
+
+// unary wraps a float function of one argument.
+func unary(name string, f func(float64) float64) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+		}
+		x, err := number(name, args[0])
+		if err != nil {
+			return err
+		}
+		return &object.Float{Value: f(x)}
+	}
+}
+
+// rounding wraps a float function whose result is a whole number, returned
+// as an integer.
+func rounding(name string, f func(float64) float64) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+		}
+		if i, ok := args[0].(*object.Integer); ok {
+			return i
+		}
+		x, err := number(name, args[0])
+		if err != nil {
+			return err
+		}
+		return &object.Integer{Value: int64(f(x))}
+	}
+}
+
+func _abs(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		if arg.Value < 0 {
+			return &object.Integer{Value: -arg.Value}
+		}
+		return arg
+	case *object.Float:
+		return &object.Float{Value: gomath.Abs(arg.Value)}
+	default:
+		return object.NewError("argument to `abs` must be a number, got %s", arg.Inspect())
+	}
+}
+
+func _min(args ...object.Object) object.Object {
+	return extreme("min", args, func(a, b float64) bool { return a < b })
+}
+
+func _max(args ...object.Object) object.Object {
+	return extreme("max", args, func(a, b float64) bool { return a > b })
+}
+
+// extreme returns the argument x for which better(x, y) holds against every
+// other argument y, keeping the first of equal arguments.
+func extreme(name string, args []object.Object, better func(a, b float64) bool) object.Object {
+	if len(args) == 0 {
+		return object.NewError("wrong number of arguments: expected at least 1, got 0")
+	}
+
+	best := args[0]
+	bestVal, err := number(name, best)
+	if err != nil {
+		return err
+	}
+	for _, arg := range args[1:] {
+		val, err := number(name, arg)
+		if err != nil {
+			return err
+		}
+		if better(val, bestVal) {
+			best, bestVal = arg, val
+		}
+	}
+	return best
+}
+
+func _pow(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return object.NewError("wrong number of arguments: expected 2, got %d", len(args))
+	}
+
+	x, err := number("pow", args[0])
+	if err != nil {
+		return err
+	}
+	y, err := number("pow", args[1])
+	if err != nil {
+		return err
+	}
+	return &object.Float{Value: gomath.Pow(x, y)}
+}
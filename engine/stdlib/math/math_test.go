@@ -0,0 +1,90 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package math
+
+import (
+	"testing"
+
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+func TestBuiltins(t *testing.T) {
+	i := func(v int64) object.Object { return &object.Integer{Value: v} }
+	f := func(v float64) object.Object { return &object.Float{Value: v} }
+	s := &object.String{Value: "x"}
+
+	tests := []struct {
+		fn   string
+		args []object.Object
+		want string
+	}{
+		{"abs", []object.Object{i(-3)}, "Int(3)"},
+		{"abs", []object.Object{i(3)}, "Int(3)"},
+		{"abs", []object.Object{f(-1.5)}, "Float(1.5)"},
+		{"abs", nil, "Error(wrong number of arguments: expected 1, got 0)"},
+		{"abs", []object.Object{s}, "Error(argument to `abs` must be a number, got x)"},
+
+		{"min", []object.Object{i(3), f(1.5), i(2)}, "Float(1.5)"},
+		{"min", []object.Object{i(1), f(1)}, "Int(1)"},
+		{"min", nil, "Error(wrong number of arguments: expected at least 1, got 0)"},
+		{"min", []object.Object{i(1), s}, "Error(argument to `min` must be a number, got x)"},
+
+		{"max", []object.Object{i(3), f(4.5), i(2)}, "Float(4.5)"},
+		{"max", []object.Object{i(7)}, "Int(7)"},
+		{"max", nil, "Error(wrong number of arguments: expected at least 1, got 0)"},
+		{"max", []object.Object{s}, "Error(argument to `max` must be a number, got x)"},
+
+		{"floor", []object.Object{f(-1.5)}, "Int(-2)"},
+		{"floor", []object.Object{i(4)}, "Int(4)"},
+		{"floor", []object.Object{i(1), i(2)}, "Error(wrong number of arguments: expected 1, got 2)"},
+		{"floor", []object.Object{s}, "Error(argument to `floor` must be a number, got x)"},
+
+		{"ceil", []object.Object{f(1.2)}, "Int(2)"},
+		{"ceil", nil, "Error(wrong number of arguments: expected 1, got 0)"},
+		{"ceil", []object.Object{s}, "Error(argument to `ceil` must be a number, got x)"},
+
+		{"round", []object.Object{f(2.5)}, "Int(3)"},
+		{"round", []object.Object{f(-2.4)}, "Int(-2)"},
+		{"round", nil, "Error(wrong number of arguments: expected 1, got 0)"},
+		{"round", []object.Object{s}, "Error(argument to `round` must be a number, got x)"},
+
+		{"sqrt", []object.Object{i(9)}, "Float(3.0)"},
+		{"sqrt", nil, "Error(wrong number of arguments: expected 1, got 0)"},
+		{"sqrt", []object.Object{s}, "Error(argument to `sqrt` must be a number, got x)"},
+
+		{"sin", []object.Object{i(0)}, "Float(0.0)"},
+		{"sin", []object.Object{i(1), i(2)}, "Error(wrong number of arguments: expected 1, got 2)"},
+		{"sin", []object.Object{s}, "Error(argument to `sin` must be a number, got x)"},
+
+		{"cos", []object.Object{f(0)}, "Float(1.0)"},
+		{"cos", nil, "Error(wrong number of arguments: expected 1, got 0)"},
+		{"cos", []object.Object{s}, "Error(argument to `cos` must be a number, got x)"},
+
+		{"tan", []object.Object{i(0)}, "Float(0.0)"},
+		{"tan", nil, "Error(wrong number of arguments: expected 1, got 0)"},
+		{"tan", []object.Object{s}, "Error(argument to `tan` must be a number, got x)"},
+
+		{"log", []object.Object{i(1)}, "Float(0.0)"},
+		{"log", []object.Object{i(0)}, "Float(-Inf)"},
+		{"log", nil, "Error(wrong number of arguments: expected 1, got 0)"},
+		{"log", []object.Object{s}, "Error(argument to `log` must be a number, got x)"},
+
+		{"pow", []object.Object{i(2), i(10)}, "Float(1024.0)"},
+		{"pow", []object.Object{f(4), f(0.5)}, "Float(2.0)"},
+		{"pow", []object.Object{i(2)}, "Error(wrong number of arguments: expected 2, got 1)"},
+		{"pow", []object.Object{s, i(2)}, "Error(argument to `pow` must be a number, got x)"},
+		{"pow", []object.Object{i(2), s}, "Error(argument to `pow` must be a number, got x)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fn, func(t *testing.T) {
+			got := Builtins[tt.fn](tt.args...)
+			if got.String() != tt.want {
+				t.Errorf("%s%s: got %s, want %s", tt.fn, tt.args, got, tt.want)
+			}
+		})
+	}
+}
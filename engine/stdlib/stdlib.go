@@ -0,0 +1,33 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package stdlib is the registry of the native modules that Ponic programs
+// import by name, as in import "math".
+package stdlib
+
+import (
+	"github.com/danecwalker/ponic/engine/object"
+	"github.com/danecwalker/ponic/engine/stdlib/math"
+	"github.com/danecwalker/ponic/engine/stdlib/strings"
+	"github.com/danecwalker/ponic/engine/stdlib/time"
+)
+
+// Module is the table of builtins and constants a native module exports.
+type Module struct {
+	Builtins  map[string]object.BuiltinFunction
+	Constants map[string]object.Object
+}
+
+var modules = map[string]Module{
+	"math":    {Builtins: math.Builtins, Constants: math.Constants},
+	"strings": {Builtins: strings.Builtins, Constants: strings.Constants},
+	"time":    {Builtins: time.Builtins, Constants: time.Constants},
+}
+
+// Lookup returns the native module called name.
+func Lookup(name string) (Module, bool) {
+	m, ok := modules[name]
+	return m, ok
+}
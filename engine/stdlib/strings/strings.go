@@ -0,0 +1,171 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package strings is the native strings module.
+package strings
+
+import (
+	gostrings "strings"
+
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+var Builtins = map[string]object.BuiltinFunction{
+	"upper":     _upper,
+	"lower":     _lower,
+	"trim":      _trim,
+	"split":     _split,
+	"join":      _join,
+	"contains":  _contains,
+	"hasPrefix": _hasPrefix,
+	"hasSuffix": _hasSuffix,
+	"index":     _index,
+	"replace":   _replace,
+	"repeat":    _repeat,
+}
+
+var Constants = map[string]object.Object{}
+
+// strArgs checks that args are n strings and returns their values.
+func strArgs(name string, args []object.Object, n int) ([]string, *object.Error) {
+	if len(args) != n {
+		return nil, object.NewError("wrong number of arguments: expected %d, got %d", n, len(args))
+	}
+
+	vals := make([]string, n)
+	for i, arg := range args {
+		s, ok := arg.(*object.String)
+		if !ok {
+			return nil, object.NewError("arguments to `%s` must be strings, got %s", name, arg.Inspect())
+		}
+		vals[i] = s.Value
+	}
+	return vals, nil
+}
+
+func _upper(args ...object.Object) object.Object {
+	vals, err := strArgs("upper", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: gostrings.ToUpper(vals[0])}
+}
+
+func _lower(args ...object.Object) object.Object {
+	vals, err := strArgs("lower", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: gostrings.ToLower(vals[0])}
+}
+
+func _trim(args ...object.Object) object.Object {
+	vals, err := strArgs("trim", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: gostrings.TrimSpace(vals[0])}
+}
+
+func _split(args ...object.Object) object.Object {
+	vals, err := strArgs("split", args, 2)
+	if err != nil {
+		return err
+	}
+
+	parts := gostrings.Split(vals[0], vals[1])
+	elements := make([]object.Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &object.String{Value: part}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func _join(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return object.NewError("wrong number of arguments: expected 2, got %d", len(args))
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return object.NewError("first argument to `join` must be an array, got %s", args[0].Inspect())
+	}
+	sep, ok := args[1].(*object.String)
+	if !ok {
+		return object.NewError("second argument to `join` must be a string, got %s", args[1].Inspect())
+	}
+
+	parts := make([]string, len(arr.Elements))
+	for i, el := range arr.Elements {
+		s, ok := el.(*object.String)
+		if !ok {
+			return object.NewError("elements joined by `join` must be strings, got %s", el.Inspect())
+		}
+		parts[i] = s.Value
+	}
+	return &object.String{Value: gostrings.Join(parts, sep.Value)}
+}
+
+func _contains(args ...object.Object) object.Object {
+	vals, err := strArgs("contains", args, 2)
+	if err != nil {
+		return err
+	}
+	return &object.Boolean{Value: gostrings.Contains(vals[0], vals[1])}
+}
+
+func _hasPrefix(args ...object.Object) object.Object {
+	vals, err := strArgs("hasPrefix", args, 2)
+	if err != nil {
+		return err
+	}
+	return &object.Boolean{Value: gostrings.HasPrefix(vals[0], vals[1])}
+}
+
+func _hasSuffix(args ...object.Object) object.Object {
+	vals, err := strArgs("hasSuffix", args, 2)
+	if err != nil {
+		return err
+	}
+	return &object.Boolean{Value: gostrings.HasSuffix(vals[0], vals[1])}
+}
+
+// _index returns the byte offset of the first instance of the substring, or
+// -1 if it is not present.
+func _index(args ...object.Object) object.Object {
+	vals, err := strArgs("index", args, 2)
+	if err != nil {
+		return err
+	}
+	return &object.Integer{Value: int64(gostrings.Index(vals[0], vals[1]))}
+}
+
+// _replace replaces every instance of old with new.
+func _replace(args ...object.Object) object.Object {
+	vals, err := strArgs("replace", args, 3)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: gostrings.ReplaceAll(vals[0], vals[1], vals[2])}
+}
+
+func _repeat(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return object.NewError("wrong number of arguments: expected 2, got %d", len(args))
+	}
+
+	s, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewError("first argument to `repeat` must be a string, got %s", args[0].Inspect())
+	}
+	n, ok := args[1].(*object.Integer)
+	if !ok {
+		return object.NewError("second argument to `repeat` must be an integer, got %s", args[1].Inspect())
+	}
+	if n.Value < 0 {
+		return object.NewError("negative repeat count %d", n.Value)
+	}
+	return &object.String{Value: gostrings.Repeat(s.Value, int(n.Value))}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package strings
+
+import (
+	"testing"
+
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+func TestBuiltins(t *testing.T) {
+	s := func(v string) object.Object { return &object.String{Value: v} }
+	i := func(v int64) object.Object { return &object.Integer{Value: v} }
+	arr := func(elements ...object.Object) object.Object { return &object.Array{Elements: elements} }
+
+	tests := []struct {
+		fn   string
+		args []object.Object
+		want string
+	}{
+		{"upper", []object.Object{s("Hi there")}, "String(HI THERE)"},
+		{"upper", nil, "Error(wrong number of arguments: expected 1, got 0)"},
+		{"upper", []object.Object{i(1)}, "Error(arguments to `upper` must be strings, got 1)"},
+
+		{"lower", []object.Object{s("Hi There")}, "String(hi there)"},
+		{"lower", []object.Object{s("a"), s("b")}, "Error(wrong number of arguments: expected 1, got 2)"},
+		{"lower", []object.Object{i(1)}, "Error(arguments to `lower` must be strings, got 1)"},
+
+		{"trim", []object.Object{s(" \tpadded\n")}, "String(padded)"},
+		{"trim", nil, "Error(wrong number of arguments: expected 1, got 0)"},
+		{"trim", []object.Object{i(1)}, "Error(arguments to `trim` must be strings, got 1)"},
+
+		{"split", []object.Object{s("a,b,,c"), s(",")}, "Array([String(a) String(b) String() String(c)])"},
+		{"split", []object.Object{s("a,b")}, "Error(wrong number of arguments: expected 2, got 1)"},
+		{"split", []object.Object{s("a,b"), i(1)}, "Error(arguments to `split` must be strings, got 1)"},
+
+		{"join", []object.Object{arr(s("a"), s("b")), s("-")}, "String(a-b)"},
+		{"join", []object.Object{arr(), s("-")}, "String()"},
+		{"join", []object.Object{arr(s("a"))}, "Error(wrong number of arguments: expected 2, got 1)"},
+		{"join", []object.Object{s("ab"), s("-")}, "Error(first argument to `join` must be an array, got ab)"},
+		{"join", []object.Object{arr(s("a")), i(1)}, "Error(second argument to `join` must be a string, got 1)"},
+		{"join", []object.Object{arr(s("a"), i(2)), s("-")}, "Error(elements joined by `join` must be strings, got 2)"},
+
+		{"contains", []object.Object{s("seafood"), s("foo")}, "Boolean(true)"},
+		{"contains", []object.Object{s("seafood"), s("bar")}, "Boolean(false)"},
+		{"contains", []object.Object{s("seafood")}, "Error(wrong number of arguments: expected 2, got 1)"},
+		{"contains", []object.Object{i(1), s("1")}, "Error(arguments to `contains` must be strings, got 1)"},
+
+		{"hasPrefix", []object.Object{s("ponic"), s("po")}, "Boolean(true)"},
+		{"hasPrefix", []object.Object{s("ponic"), s("ic")}, "Boolean(false)"},
+		{"hasPrefix", nil, "Error(wrong number of arguments: expected 2, got 0)"},
+		{"hasPrefix", []object.Object{s("ponic"), i(1)}, "Error(arguments to `hasPrefix` must be strings, got 1)"},
+
+		{"hasSuffix", []object.Object{s("ponic"), s("ic")}, "Boolean(true)"},
+		{"hasSuffix", []object.Object{s("ponic"), s("po")}, "Boolean(false)"},
+		{"hasSuffix", nil, "Error(wrong number of arguments: expected 2, got 0)"},
+		{"hasSuffix", []object.Object{s("ponic"), i(1)}, "Error(arguments to `hasSuffix` must be strings, got 1)"},
+
+		{"index", []object.Object{s("chicken"), s("ken")}, "Int(4)"},
+		{"index", []object.Object{s("chicken"), s("dmr")}, "Int(-1)"},
+		{"index", []object.Object{s("chicken")}, "Error(wrong number of arguments: expected 2, got 1)"},
+		{"index", []object.Object{i(1), s("1")}, "Error(arguments to `index` must be strings, got 1)"},
+
+		{"replace", []object.Object{s("oink oink"), s("k"), s("ky")}, "String(oinky oinky)"},
+		{"replace", []object.Object{s("oink"), s("k")}, "Error(wrong number of arguments: expected 3, got 2)"},
+		{"replace", []object.Object{s("oink"), s("k"), i(1)}, "Error(arguments to `replace` must be strings, got 1)"},
+
+		{"repeat", []object.Object{s("ab"), i(3)}, "String(ababab)"},
+		{"repeat", []object.Object{s("ab"), i(0)}, "String()"},
+		{"repeat", []object.Object{s("ab")}, "Error(wrong number of arguments: expected 2, got 1)"},
+		{"repeat", []object.Object{i(1), i(3)}, "Error(first argument to `repeat` must be a string, got 1)"},
+		{"repeat", []object.Object{s("ab"), s("3")}, "Error(second argument to `repeat` must be an integer, got 3)"},
+		{"repeat", []object.Object{s("ab"), i(-1)}, "Error(negative repeat count -1)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fn, func(t *testing.T) {
+			got := Builtins[tt.fn](tt.args...)
+			if got.String() != tt.want {
+				t.Errorf("%s%s: got %s, want %s", tt.fn, tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package time is the native time module. Times and durations are integer
+// milliseconds; times count from the Unix epoch.
+package time
+
+import (
+	gotime "time"
+
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+var Builtins = map[string]object.BuiltinFunction{
+	"now":    _now,
+	"since":  _since,
+	"sleep":  _sleep,
+	"format": _format,
+}
+
+var Constants = map[string]object.Object{
+	"millisecond": &object.Integer{Value: 1},
+	"second":      &object.Integer{Value: 1000},
+	"minute":      &object.Integer{Value: 60 * 1000},
+	"hour":        &object.Integer{Value: 60 * 60 * 1000},
+}
+
+func millis(name string, arg object.Object) (int64, *object.Error) {
+	ms, ok := arg.(*object.Integer)
+	if !ok {
+		return 0, object.NewError("argument to `%s` must be an integer, got %s", name, arg.Inspect())
+	}
+	return ms.Value, nil
+}
+
+func _now(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return object.NewError("wrong number of arguments: expected 0, got %d", len(args))
+	}
+	return &object.Integer{Value: gotime.Now().UnixMilli()}
+}
+
+// _since returns the milliseconds elapsed since the given time.
+func _since(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+	}
+	start, err := millis("since", args[0])
+	if err != nil {
+		return err
+	}
+	return &object.Integer{Value: gotime.Now().UnixMilli() - start}
+}
+
+func _sleep(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+	}
+	ms, err := millis("sleep", args[0])
+	if err != nil {
+		return err
+	}
+	gotime.Sleep(gotime.Duration(ms) * gotime.Millisecond)
+	return &object.Null{}
+}
+
+// _format formats a time as RFC 3339 in local time.
+func _format(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments: expected 1, got %d", len(args))
+	}
+	ms, err := millis("format", args[0])
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: gotime.UnixMilli(ms).Format(gotime.RFC3339)}
+}
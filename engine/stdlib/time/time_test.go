@@ -0,0 +1,67 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package time
+
+import (
+	"testing"
+	gotime "time"
+
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+func TestBuiltins(t *testing.T) {
+	i := func(v int64) object.Object { return &object.Integer{Value: v} }
+	s := &object.String{Value: "x"}
+
+	tests := []struct {
+		fn   string
+		args []object.Object
+		want string
+	}{
+		{"now", []object.Object{i(1)}, "Error(wrong number of arguments: expected 0, got 1)"},
+
+		{"since", nil, "Error(wrong number of arguments: expected 1, got 0)"},
+		{"since", []object.Object{s}, "Error(argument to `since` must be an integer, got x)"},
+
+		{"sleep", []object.Object{i(0)}, "Null()"},
+		{"sleep", nil, "Error(wrong number of arguments: expected 1, got 0)"},
+		{"sleep", []object.Object{&object.Float{Value: 1.5}}, "Error(argument to `sleep` must be an integer, got 1.5)"},
+
+		{"format", []object.Object{i(1_500_000)}, "String(" + gotime.UnixMilli(1_500_000).Format(gotime.RFC3339) + ")"},
+		{"format", []object.Object{i(1), i(2)}, "Error(wrong number of arguments: expected 1, got 2)"},
+		{"format", []object.Object{s}, "Error(argument to `format` must be an integer, got x)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fn, func(t *testing.T) {
+			got := Builtins[tt.fn](tt.args...)
+			if got.String() != tt.want {
+				t.Errorf("%s%s: got %s, want %s", tt.fn, tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestClock checks the builtins that read the clock against the clock of
+// the test.
+func TestClock(t *testing.T) {
+	before := gotime.Now().UnixMilli()
+	now := Builtins["now"]().(*object.Integer).Value
+	if after := gotime.Now().UnixMilli(); now < before || now > after {
+		t.Errorf("got now %d, want between %d and %d", now, before, after)
+	}
+
+	start := gotime.Now()
+	Builtins["sleep"](&object.Integer{Value: 20})
+	if slept := gotime.Since(start); slept < 20*gotime.Millisecond {
+		t.Errorf("sleep(20) returned after %s", slept)
+	}
+
+	since := Builtins["since"](&object.Integer{Value: start.UnixMilli()}).(*object.Integer).Value
+	if since < 20 || since > gotime.Since(start).Milliseconds()+1 {
+		t.Errorf("got since %d, want the %s slept", since, gotime.Since(start))
+	}
+}
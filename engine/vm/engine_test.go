@@ -0,0 +1,518 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package vm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danecwalker/ponic/engine/internal/testutil"
+	"github.com/danecwalker/ponic/engine/object"
+	"github.com/danecwalker/ponic/engine/runtime"
+)
+
+// runFile runs main.pc in dir as a script, on the walker if engine is nil
+// and on engine otherwise.
+func runFile(t *testing.T, dir string, engine runtime.Engine) object.Object {
+	t.Helper()
+	path := filepath.Join(dir, "main.pc")
+	src, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := testutil.Parse(t, string(src))
+
+	l := runtime.NewLoader()
+	l.Engine = engine
+	_, result := l.Exec(path, program)
+	return result
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// describe renders a result for comparison: the value it inspects as, or
+// the full message and stack trace of an error.
+func describe(obj object.Object, dir string) string {
+	if err, ok := obj.(*object.Error); ok {
+		return "error: " + strings.ReplaceAll(err.Error(), dir+string(filepath.Separator), "")
+	}
+	if ret, ok := obj.(*object.ReturnValue); ok {
+		return "return " + ret.Value.Inspect()
+	}
+	return obj.Inspect()
+}
+
+// TestEnginesAgree runs each program on the walker and on the vm and checks
+// that both give the expected result or error.
+func TestEnginesAgree(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "arithmetic",
+			src:  `[1 + 2 * 3, 7 / 2, 7 % 3, 1.5 * 2, 2 - 0.5, "a" + "b", 3 > 2, 2 <= 1, "x" == "x", true != false]`,
+			want: `[7, 3, 1, 3.0, 1.5, "ab", true, false, true, true]`,
+		},
+		{
+			name: "truthiness and logical operators",
+			src: `
+let hits = 0;
+fn hit() { hits += 1; return true; }
+let got = [false && hit(), true || hit(), true && hit(), false || 5, 1 && 2, !0, !false, !fn() {}()];
+if (1) { push(got, "int is truthy"); }
+push(got, hits);
+got`,
+			want: `[false, true, true, 5, 1, false, true, true, 1]`,
+		},
+		{
+			name: "if else chains",
+			src: `
+fn grade(n) {
+	if (n >= 90) { "a" } else if (n >= 80) { "b" } else { "c" }
+}
+let got = [grade(95), grade(85), grade(10)];
+got`,
+			want: `["a", "b", "c"]`,
+		},
+		{
+			name: "arrays and hashes",
+			src: `
+let a = [1, 2, 3];
+a[0] += 10;
+push(a, 4);
+let h = {"x": 1, 2: "two", true: [a[0]]};
+h["x"] *= 5;
+h["y"] = len(a);
+[a, h, keys(h), len("héllo"), slice(a, 1, 3)]`,
+			want: `[[11, 2, 3, 4], {"x": 5, 2: "two", true: [11], "y": 4}, ["x", 2, true, "y"], 5, [2, 3]]`,
+		},
+		{
+			name: "closures",
+			src: `
+fn counter() {
+	let n = 0;
+	return fn() { n += 1; return n; };
+}
+let a = counter();
+let b = counter();
+a(); a(); b();
+fn make() {
+	let x = 1;
+	let get = fn() { return x; };
+	x = 2;
+	return get;
+}
+let got = [a(), b(), make()()];
+got`,
+			want: `[3, 2, 2]`,
+		},
+		{
+			name: "recursion and mutual recursion",
+			src: `
+fn fib(n) { if (n < 2) { return n; } return fib(n - 1) + fib(n - 2); }
+fn parity(n) {
+	fn isEven(n) { if (n == 0) { return true; } return isOdd(n - 1); }
+	fn isOdd(n) { if (n == 0) { return false; } return isEven(n - 1); }
+	return isEven(n);
+}
+let got = [fib(15), parity(10), parity(7)];
+got`,
+			want: `[610, true, false]`,
+		},
+		{
+			name: "for loops with break and continue",
+			src: `
+let evens = [];
+for (let i = 0; i < 20; i += 1) {
+	if (i % 2 == 1) { continue; }
+	if (i > 10) { break; }
+	push(evens, i);
+}
+let n = 0;
+for (n < 5) { n += 1; }
+let got = [evens, n];
+got`,
+			want: `[[0, 2, 4, 6, 8, 10], 5]`,
+		},
+		{
+			name: "for-in over collections",
+			src: `
+let got = [];
+for (v in [1, 2, 3]) { push(got, v * 10); }
+for (i, c in "héj") { push(got, [i, c]); }
+for (k, v in {"a": 1, "b": 2}) { push(got, [k, v]); }
+for (i in range(10)) {
+	if (i == 2) { continue; }
+	if (i == 4) { break; }
+	push(got, i);
+}
+got`,
+			want: `[10, 20, 30, [0, "h"], [1, "é"], [2, "j"], ["a", 1], ["b", 2], 0, 1, 3]`,
+		},
+		{
+			name: "for-in captures each iteration",
+			src: `
+let fns = [];
+for (v in [1, 2, 3]) { push(fns, fn() { return v; }); }
+let got = [fns[0](), fns[1](), fns[2]()];
+got`,
+			want: `[1, 2, 3]`,
+		},
+		{
+			name: "return from nested loops",
+			src: `
+fn find(rows, x) {
+	for (i, row in rows) {
+		for (j, v in row) {
+			if (v == x) { return [i, j]; }
+		}
+	}
+	return [];
+}
+find([[1, 2], [3, 4]], 4)`,
+			want: `[1, 1]`,
+		},
+		{
+			name: "top-level return",
+			src: `
+let x = 3;
+if (x > 2) { return x * 2; }
+x`,
+			want: `return 6`,
+		},
+		{
+			name: "imports",
+			files: map[string]string{
+				"lib/shapes.pc": `
+export const sides = 4;
+export fn area(w, h) { return w * h; }
+let hidden = 1;`,
+			},
+			src: `
+import "./lib/shapes.pc"
+import "./lib/shapes.pc" as again
+import "math"
+[shapes.area(2, 3), shapes.sides, again.sides, math.max(1, 7), math.floor(2.7)]`,
+			want: `[6, 4, 4, 7, 2]`,
+		},
+		{
+			name: "undefined variable",
+			src: `
+fn f() { return missing; }
+f()`,
+			want: `error: main.pc:2:17: Undefined variable missing`,
+		},
+		{
+			name: "division by zero with a stack trace",
+			src: `
+fn inner(n) { return 10 / n; }
+fn outer(n) { return inner(n); }
+outer(0)`,
+			want: "error: main.pc:2:25: division by zero\n    at inner (main.pc:3:22)\n    at outer (main.pc:4:1)",
+		},
+		{
+			name: "index out of range",
+			src: `
+let a = [1, 2];
+a[5]`,
+			want: `error: main.pc:3:2: array index 5 out of range with length 2`,
+		},
+		{
+			name: "wrong number of arguments",
+			src: `
+fn f(a, b) { return a; }
+f(1)`,
+			want: "error: main.pc:3:1: wrong number of arguments: expected 2, got 1\n    at f (main.pc:3:1)",
+		},
+		{
+			name: "calling a non-function",
+			src: `
+let x = 1;
+x()`,
+			want: `error: main.pc:3:1: 1 is not a function`,
+		},
+		{
+			name: "reassigning a constant",
+			src: `
+const limit = 3;
+fn raise() { limit = 4; }`,
+			want: `error: main.pc:3:14: Cannot reassign constant limit`,
+		},
+		{
+			name: "error in an imported module",
+			files: map[string]string{
+				"broken.pc": `
+export fn fail() { return [][0]; }`,
+			},
+			src: `
+import "./broken.pc"
+broken.fail()`,
+			want: "error: broken.pc:2:29: array index 0 out of range with length 0\n    at broken.fail (main.pc:3:7)",
+		},
+		{
+			name: "deep error trace",
+			src: `
+fn down(n) {
+	if (n == 0) { return 1 / 0; }
+	return down(n - 1);
+}
+down(200)`,
+			want: "error: main.pc:3:25: division by zero" +
+				strings.Repeat("\n    at down (main.pc:4:9)", 10) +
+				"\n    ... 181 more frames" +
+				strings.Repeat("\n    at down (main.pc:4:9)", 9) +
+				"\n    at down (main.pc:6:1)",
+		},
+		{
+			name: "unknown module",
+			src:  `import "nope"`,
+			want: `error: main.pc:1:1: unknown module nope`,
+		},
+		{
+			name: "structs and methods",
+			src: `
+struct Point { x, y }
+fn Point.add(self, other) { return Point(self.x + other.x, self.y + other.y); }
+fn Point.scale(p, k) { p.x *= k; p.y *= k; }
+let a = Point(1, 2);
+let b = a.add(Point(10, 20));
+b.scale(2);
+let add = a.add;
+let got = [a, b, add(a), Point.add(b, b).y, Point];
+got`,
+			want: `[Point{x: 1, y: 2}, Point{x: 22, y: 44}, Point{x: 2, y: 4}, 88, struct Point]`,
+		},
+		{
+			name: "struct declared in a function",
+			src: `
+fn make() {
+	struct Box { v }
+	fn Box.get(self) { return self.v; }
+	return Box;
+}
+let one = make();
+let two = make();
+fn two.get(self) { return -self.v; }
+let got = [one(1).get(), two(1).get()];
+got`,
+			want: `[1, -1]`,
+		},
+		{
+			name: "method conflicting with a field",
+			src: `
+struct Point { x, y }
+fn Point.x(self) { return 0; }`,
+			want: `error: main.pc:3:10: method Point.x conflicts with a field`,
+		},
+		{
+			name: "method on a value that is not a struct",
+			src: `
+let n = 1;
+fn n.double(self) { return 2; }`,
+			want: `error: main.pc:3:6: cannot declare method on 1`,
+		},
+		{
+			name: "duplicate field",
+			src: `
+let before = 1;
+struct Point { x, y, x }`,
+			want: `error: main.pc:3:22: duplicate field x in struct Point`,
+		},
+		{
+			name: "missing field",
+			src: `
+struct Point { x, y }
+Point(1).z`,
+			want: "error: main.pc:3:1: wrong number of fields for Point: expected 2, got 1\n    at Point (main.pc:3:1)",
+		},
+		{
+			name: "try and catch",
+			src: `
+fn check(n) { if (n < 0) { throw {"negative": n}; } return n; }
+let got = [
+	try { check(1) } catch (e) { e },
+	try { check(-1) } catch (e) { e },
+	try { [][1] } catch (e) { e },
+	try { 1 / 0 } catch { "failed" }
+];
+got`,
+			want: `[1, {"negative": -1}, "array index 1 out of range with length 0", "failed"]`,
+		},
+		{
+			name: "finally on every way out",
+			src: `
+let log = [];
+fn returns() {
+	try { return "body"; } finally { push(log, "return"); }
+}
+fn fails() {
+	try { throw "inner"; } catch (e) { throw e + " again"; } finally { push(log, "throw"); }
+}
+for (i in range(5)) {
+	try {
+		if (i == 1) { continue; }
+		if (i == 2) { break; }
+	} finally { push(log, i); }
+}
+let got = [returns(), try { fails() } catch (e) { e }, log];
+got`,
+			want: `["body", "inner again", [0, 1, 2, "return", "throw"]]`,
+		},
+		{
+			name: "finally overrides the result",
+			src: `
+fn f() { try { return 1; } finally { return 2; } }
+fn g() { try { throw "lost"; } finally { return "kept"; } }
+let broke = [];
+for (i in range(3)) {
+	try { throw i; } finally { push(broke, i); break; }
+}
+let got = [f(), g(), broke];
+got`,
+			want: `[2, "kept", [0]]`,
+		},
+		{
+			name: "nested try in loops",
+			src: `
+let log = [];
+fn run() {
+	for (i in range(3)) {
+		try {
+			for (j in range(3)) {
+				try {
+					if (j == 1) { continue; }
+					if (i == 1) { break; }
+					if (i == 2) { return log; }
+					push(log, [i, j]);
+				} finally { push(log, "j"); }
+			}
+		} finally { push(log, "i"); }
+	}
+}
+run()`,
+			want: `[[0, 0], "j", "j", [0, 2], "j", "i", "j", "i", "j", "i"]`,
+		},
+		{
+			name: "uncaught throw",
+			src: `
+fn thrower() { throw {"code": 7}; }
+fn outer() { try { thrower(); } finally { 1; } }
+outer()`,
+			want: "error: main.pc:2:16: {\"code\": 7}\n    at thrower (main.pc:3:20)\n    at outer (main.pc:4:1)",
+		},
+		{
+			name: "generators",
+			src: `
+fn count(n) {
+	for (let i = 0; i < n; i += 1) { yield i; }
+	return "ignored";
+}
+let got = [];
+for (v in count(3)) { push(got, v); }
+let g = count(2);
+push(got, [next(g), next(g), next(g), next(g)]);
+for (i, v in count(2)) { push(got, [i, v]); }
+got`,
+			want: `[0, 1, 2, [0, 1, null, null], [0, 0], [1, 1]]`,
+		},
+		{
+			name: "closing a generator runs its finally blocks",
+			src: `
+let log = [];
+fn naturals() {
+	let i = 0;
+	try {
+		try {
+			for (true) { yield i; i += 1; }
+		} catch (e) {
+			push(log, "never caught");
+		}
+	} finally {
+		push(log, ["closed at", i]);
+		yield "ignored";
+		push(log, "unreachable");
+	}
+}
+fn firstOver(n) {
+	for (v in naturals()) { if (v > n) { return v; } }
+}
+for (v in naturals()) { if (v == 2) { break; } }
+let got = [firstOver(3), log];
+got`,
+			want: `[4, [["closed at", 2], ["closed at", 4]]]`,
+		},
+		{
+			name: "error in a generator",
+			src: `
+fn gen() {
+	yield 1;
+	yield [][0];
+}
+let got = [];
+for (v in gen()) { push(got, v); }`,
+			want: "error: main.pc:4:10: array index 0 out of range with length 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{"main.pc": tt.src}
+			for name, src := range tt.files {
+				files[name] = src
+			}
+			dir := writeFiles(t, files)
+
+			walker := describe(runFile(t, dir, nil), dir)
+			vm := describe(runFile(t, dir, New()), dir)
+			if walker != tt.want {
+				t.Errorf("walker: got\n%s\nwant\n%s", walker, tt.want)
+			}
+			if vm != tt.want {
+				t.Errorf("vm: got\n%s\nwant\n%s", vm, tt.want)
+			}
+		})
+	}
+}
+
+// TestStackOverflowTrace checks that both engines stop a runaway recursion
+// after the same number of calls and keep only both ends of its trace.
+func TestStackOverflowTrace(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.pc": `
+fn down(n) { return down(n + 1); }
+down(0)`})
+
+	inner := strings.Repeat("\n    at down (main.pc:2:21)", maxTraceFrames)
+	outer := strings.Repeat("\n    at down (main.pc:2:21)", maxTraceFrames-1)
+	// the calls in progress and the one that overflowed
+	elided := runtime.MaxCallDepth + 1 - 2*maxTraceFrames
+	want := fmt.Sprintf("error: main.pc:2:21: stack overflow%s\n    ... %d more frames%s\n    at down (main.pc:3:1)", inner, elided, outer)
+
+	if got := describe(runFile(t, dir, nil), dir); got != want {
+		t.Errorf("walker: got\n%s\nwant\n%s", got, want)
+	}
+	if got := describe(runFile(t, dir, New()), dir); got != want {
+		t.Errorf("vm: got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package vm
+
+import (
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+const generatorStackSize = 64
+
+// generator runs the body of a generator function on a vm of its own. The
+// vm stops at each yield and picks up where it left off on the next call to
+// Next, so the body needs no goroutine, and a generator that is dropped
+// before it finishes is simply collected.
+type generator struct {
+	vm      *vm
+	done    bool
+	running bool
+}
+
+// closed unwinds the body of a generator that is closed while it is
+// suspended at a yield. catch clauses do not stop it, but finally blocks
+// run.
+type closed struct{}
+
+func (c *closed) Type() object.Type {
+	return object.NULL
+}
+func (c *closed) Inspect() string {
+	return "generator closed"
+}
+func (c *closed) String() string {
+	return "GeneratorClosed()"
+}
+
+// newGenerator returns a generator that runs the body of cl with args.
+func newGenerator(cl *Closure, args []object.Object) *generator {
+	vm := &vm{stack: make([]object.Object, generatorStackSize)}
+	vm.frames = append(vm.frames, frame{cl: cl})
+	vm.reserve(cl.Fn.NumLocals)
+	for i, arg := range args {
+		vm.stack[i] = &cell{Value: arg}
+	}
+	vm.sp = cl.Fn.NumLocals
+	return &generator{vm: vm}
+}
+
+func (g *generator) Next() (object.Object, bool) {
+	if g.done {
+		return nil, false
+	}
+	if g.running {
+		return object.NewError("generator is already running"), true
+	}
+
+	g.running = true
+	val, yielded := g.vm.loop()
+	g.running = false
+	if yielded {
+		return val, true
+	}
+
+	g.done = true
+	if err, ok := val.(*object.Error); ok {
+		return err, true
+	}
+	return nil, false
+}
+
+// Close stops a suspended generator, letting its body unwind through any
+// finally blocks before returning. A yield in a finally block carries on
+// unwinding.
+func (g *generator) Close() {
+	if g.done || g.running {
+		return
+	}
+	g.done = true
+	g.running = true
+	defer func() { g.running = false }()
+
+	for g.vm.throw(&closed{}) == nil {
+		if _, yielded := g.vm.loop(); !yielded {
+			return
+		}
+	}
+}
+
+func (g *generator) Type() object.Type {
+	return object.ITERATOR
+}
+func (g *generator) Inspect() string {
+	return "generator"
+}
+func (g *generator) String() string {
+	return "Generator()"
+}
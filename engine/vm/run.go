@@ -0,0 +1,579 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package vm
+
+import (
+	"github.com/danecwalker/ponic/engine/compiler"
+	"github.com/danecwalker/ponic/engine/object"
+	"github.com/danecwalker/ponic/engine/runtime"
+)
+
+// run runs the top-level code of a module and returns the value of its last
+// statement, an *object.ReturnValue for a top-level return, or an
+// *object.Error.
+func (vm *vm) run(main *Closure) object.Object {
+	vm.frames = append(vm.frames, frame{cl: main})
+	vm.reserve(main.Fn.NumLocals)
+	vm.sp = main.Fn.NumLocals
+
+	result, _ := vm.loop()
+	return result
+}
+
+// loop runs instructions from where the frame on top left off until the
+// bottom frame returns, or until a generator yields, in which case it
+// reports true.
+func (vm *vm) loop() (object.Object, bool) {
+	for {
+		f := &vm.frames[len(vm.frames)-1]
+		fn := f.cl.Fn
+		ins := fn.Instructions
+		f.op = f.ip
+		op := compiler.Opcode(ins[f.ip])
+		f.ip++
+
+		// raised is set by an instruction that fails, to unwind the stack
+		var raised object.Object
+
+		switch op {
+		case compiler.OpConstant:
+			vm.push(fn.Constants[vm.operand16(f)])
+		case compiler.OpNull:
+			vm.push(&object.Null{})
+		case compiler.OpTrue:
+			vm.push(&object.Boolean{Value: true})
+		case compiler.OpFalse:
+			vm.push(&object.Boolean{Value: false})
+		case compiler.OpPop:
+			vm.sp--
+		case compiler.OpPopN:
+			vm.sp -= vm.operand16(f)
+		case compiler.OpDup:
+			vm.push(vm.stack[vm.sp-1])
+
+		case compiler.OpBinary:
+			operator := compiler.Operators[vm.operand8(f)]
+			right, left := vm.pop(), vm.pop()
+			result := binaryOp(operator, left, right)
+			if err, ok := result.(*object.Error); ok {
+				raised = err
+				break
+			}
+			vm.push(result)
+		case compiler.OpMinus:
+			vm.push(runtime.UnaryOp("-", vm.pop()))
+		case compiler.OpBang:
+			vm.push(runtime.UnaryOp("!", vm.pop()))
+
+		case compiler.OpJump:
+			f.ip = vm.operand16(f)
+		case compiler.OpJumpIfFalse:
+			target := vm.operand16(f)
+			if !runtime.IsTruthy(vm.pop()) {
+				f.ip = target
+			}
+
+		case compiler.OpGetGlobal:
+			slot := vm.operand16(f)
+			val := f.cl.module.values[slot]
+			if val == nil {
+				raised = object.NewError("Undefined variable %s", f.cl.module.globals.Names[slot])
+				break
+			}
+			vm.push(val)
+		case compiler.OpSetGlobal:
+			slot := vm.operand16(f)
+			m := f.cl.module
+			if m.values[slot] == nil {
+				raised = object.NewError("Undefined variable %s", m.globals.Names[slot])
+				break
+			}
+			if m.globals.Constant[slot] {
+				raised = object.NewError("Cannot reassign constant %s", m.globals.Names[slot])
+				break
+			}
+			m.values[slot] = vm.pop()
+		case compiler.OpDefGlobal:
+			f.cl.module.values[vm.operand16(f)] = vm.pop()
+		case compiler.OpGetLocal:
+			c := vm.stack[f.bp+vm.operand8(f)].(*cell)
+			if c.Value == nil {
+				raised = vm.undeclared(f)
+				break
+			}
+			vm.push(c.Value)
+		case compiler.OpSetLocal:
+			c := vm.stack[f.bp+vm.operand8(f)].(*cell)
+			if c.Value == nil && fn.PositionAt(f.op).Variable != "" {
+				raised = vm.undeclared(f)
+				break
+			}
+			c.Value = vm.pop()
+		case compiler.OpDefLocal:
+			vm.stack[f.bp+vm.operand8(f)] = &cell{Value: vm.pop()}
+		case compiler.OpNewLocal:
+			vm.stack[f.bp+vm.operand8(f)] = &cell{}
+		case compiler.OpGetFree:
+			c := f.cl.Free[vm.operand8(f)]
+			if c.Value == nil {
+				raised = vm.undeclared(f)
+				break
+			}
+			vm.push(c.Value)
+		case compiler.OpSetFree:
+			c := f.cl.Free[vm.operand8(f)]
+			if c.Value == nil {
+				raised = vm.undeclared(f)
+				break
+			}
+			c.Value = vm.pop()
+		case compiler.OpGetName:
+			name := fn.Constants[vm.operand16(f)].(*object.String).Value
+			val, ok := f.cl.module.obj.Scope.Get(name)
+			if !ok {
+				val, ok = runtime.LookupBuiltin(name)
+			}
+			if !ok {
+				raised = object.NewError("Undefined variable %s", name)
+				break
+			}
+			vm.push(val)
+		case compiler.OpSetName:
+			name := fn.Constants[vm.operand16(f)].(*object.String).Value
+			if err := f.cl.module.obj.Scope.Assign(name, vm.pop()); err != nil {
+				raised = err
+			}
+
+		case compiler.OpLoadCell:
+			vm.push(vm.stack[f.bp+vm.operand8(f)])
+		case compiler.OpLoadFreeCell:
+			vm.push(f.cl.Free[vm.operand8(f)])
+		case compiler.OpClosure:
+			idx, n := vm.operand16(f), vm.operand8(f)
+			free := make([]*cell, n)
+			for i := range free {
+				free[i] = vm.stack[vm.sp-n+i].(*cell)
+			}
+			vm.sp -= n
+			vm.push(&Closure{Fn: fn.Constants[idx].(*compiler.Function), Free: free, module: f.cl.module})
+
+		case compiler.OpCall:
+			if err := vm.call(vm.operand8(f)); err != nil {
+				raised = err
+			}
+		case compiler.OpReturn:
+			val := vm.pop()
+			if len(vm.frames) == 1 {
+				vm.drop(f.bp)
+				return val, false
+			}
+			vm.drop(f.bp - 1)
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.push(val)
+		case compiler.OpExit:
+			val := vm.pop()
+			vm.drop(0)
+			return &object.ReturnValue{Value: val}, false
+
+		case compiler.OpArray:
+			n := vm.operand16(f)
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			vm.push(&object.Array{Elements: elements})
+		case compiler.OpHash:
+			n := vm.operand16(f)
+			hash := object.NewHash()
+			for i := vm.sp - 2*n; i < vm.sp; i += 2 {
+				key, err := runtime.HashKey(vm.stack[i])
+				if err != nil {
+					raised = err
+					break
+				}
+				hash.Set(key, vm.stack[i+1])
+			}
+			vm.sp -= 2 * n
+			if raised == nil {
+				vm.push(hash)
+			}
+		case compiler.OpIndex:
+			index, left := vm.pop(), vm.pop()
+			result := runtime.Index(left, index)
+			if err, ok := result.(*object.Error); ok {
+				raised = err
+				break
+			}
+			vm.push(result)
+		case compiler.OpSetIdx:
+			operator := assignOperator(vm.operand8(f))
+			val, index, container := vm.pop(), vm.pop(), vm.pop()
+			if err, ok := runtime.SetIndex(container, index, operator, val).(*object.Error); ok {
+				raised = err
+			}
+		case compiler.OpMember:
+			name := fn.Constants[vm.operand16(f)].(*object.String).Value
+			result := runtime.Member(vm.pop(), name)
+			if err, ok := result.(*object.Error); ok {
+				raised = err
+				break
+			}
+			vm.push(result)
+		case compiler.OpSetMem:
+			name := fn.Constants[vm.operand16(f)].(*object.String).Value
+			operator := assignOperator(vm.operand8(f))
+			val, obj := vm.pop(), vm.pop()
+			if err, ok := runtime.SetMember(obj, name, operator, val).(*object.Error); ok {
+				raised = err
+			}
+
+		case compiler.OpIter:
+			iterable := vm.pop()
+			iter, err := runtime.Iterate(iterable)
+			if err != nil {
+				raised = err
+				break
+			}
+			hash, _ := iterable.(*object.Hash)
+			st := &iterState{iter: iter, hash: hash}
+			if st.closer, _ = iter.(object.Closer); st.closer != nil {
+				vm.closers++
+			}
+			vm.push(st)
+		case compiler.OpIterNext:
+			target, hasKey := vm.operand16(f), vm.operand8(f) == 1
+			if err := vm.iterNext(f, target, hasKey); err != nil {
+				raised = err
+			}
+		case compiler.OpIterEnd:
+			vm.close(vm.pop().(*iterState))
+
+		case compiler.OpStruct:
+			st := fn.Constants[vm.operand16(f)].(*object.StructType)
+			vm.push(&object.StructType{Name: st.Name, Fields: st.Fields, Methods: map[string]object.Object{}})
+		case compiler.OpMethod:
+			name := fn.Constants[vm.operand16(f)].(*object.String).Value
+			receiver, method := vm.pop(), vm.pop().(*Closure)
+			if err := runtime.DeclareMethod(receiver, name, method, method.Fn.NumParams); err != nil {
+				raised = err
+			}
+
+		case compiler.OpCatch, compiler.OpFinally:
+			vm.handlers = append(vm.handlers, handler{
+				frame:  len(vm.frames) - 1,
+				sp:     vm.sp,
+				target: vm.operand16(f),
+				catch:  op == compiler.OpCatch,
+			})
+		case compiler.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case compiler.OpThrow:
+			raised = runtime.Throw(vm.pop())
+		case compiler.OpRethrow:
+			raised = vm.pop()
+
+		case compiler.OpYield:
+			return vm.pop(), true
+
+		case compiler.OpImport:
+			path := fn.Constants[vm.operand16(f)].(*object.String).Value
+			mod, err := vm.importModule(f, path)
+			if err != nil {
+				raised = err
+				break
+			}
+			vm.push(mod)
+
+		default:
+			raised = object.NewError("unknown opcode %d", op)
+		}
+
+		if raised != nil {
+			if result := vm.throw(raised); result != nil {
+				return result, false
+			}
+		}
+	}
+}
+
+func (vm *vm) operand8(f *frame) int {
+	v := int(f.cl.Fn.Instructions[f.ip])
+	f.ip++
+	return v
+}
+
+func (vm *vm) operand16(f *frame) int {
+	v := int(compiler.ReadUint16(f.cl.Fn.Instructions[f.ip:]))
+	f.ip += 2
+	return v
+}
+
+func (vm *vm) push(obj object.Object) {
+	if vm.sp == len(vm.stack) {
+		vm.reserve(1)
+	}
+	vm.stack[vm.sp] = obj
+	vm.sp++
+}
+
+func (vm *vm) pop() object.Object {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+// reserve makes room for n more values above sp.
+func (vm *vm) reserve(n int) {
+	if vm.sp+n <= len(vm.stack) {
+		return
+	}
+	size := 2 * len(vm.stack)
+	for size < vm.sp+n {
+		size *= 2
+	}
+	stack := make([]object.Object, size)
+	copy(stack, vm.stack[:vm.sp])
+	vm.stack = stack
+}
+
+// undeclared is the error for an access to a hoisted variable before its
+// declaration has run.
+func (vm *vm) undeclared(f *frame) *object.Error {
+	return object.NewError("%s used before its declaration", f.cl.Fn.PositionAt(f.op).Variable)
+}
+
+// binaryOp computes the common integer operators directly and leaves the
+// rest to the runtime.
+func binaryOp(operator string, left, right object.Object) object.Object {
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if lok && rok {
+		switch operator {
+		case "+":
+			return &object.Integer{Value: l.Value + r.Value}
+		case "-":
+			return &object.Integer{Value: l.Value - r.Value}
+		case "*":
+			return &object.Integer{Value: l.Value * r.Value}
+		case "<":
+			return &object.Boolean{Value: l.Value < r.Value}
+		case ">":
+			return &object.Boolean{Value: l.Value > r.Value}
+		case "<=":
+			return &object.Boolean{Value: l.Value <= r.Value}
+		case ">=":
+			return &object.Boolean{Value: l.Value >= r.Value}
+		case "==":
+			return &object.Boolean{Value: l.Value == r.Value}
+		case "!=":
+			return &object.Boolean{Value: l.Value != r.Value}
+		}
+	}
+	return runtime.BinaryOp(operator, left, right)
+}
+
+// assignOperator decodes the operator operand of OpSetIdx and OpSetMem.
+func assignOperator(index int) string {
+	op := compiler.Operators[index]
+	if op == "=" {
+		return op
+	}
+	return op + "="
+}
+
+// call calls the function below the argc arguments on top of the stack. A
+// closure gets a new frame, and a method the receiver as an extra first
+// argument; anything else is called through the runtime and replaced with
+// its result right away. Calling a generator function creates a generator
+// without running any of its body.
+func (vm *vm) call(argc int) *object.Error {
+	callee := vm.stack[vm.sp-1-argc]
+
+	if bm, ok := callee.(*object.BoundMethod); ok {
+		if cl, ok := bm.Method.(*Closure); ok {
+			vm.reserve(1)
+			copy(vm.stack[vm.sp-argc+1:], vm.stack[vm.sp-argc:vm.sp])
+			vm.stack[vm.sp-argc-1], vm.stack[vm.sp-argc] = cl, bm.Receiver
+			vm.sp++
+			return vm.call(argc + 1)
+		}
+	}
+
+	cl, ok := callee.(*Closure)
+	if !ok {
+		args := make([]object.Object, argc)
+		copy(args, vm.stack[vm.sp-argc:vm.sp])
+		result, ok := runtime.Call(callee, args)
+		if !ok {
+			return object.NewError("%s is not a function", callee.Inspect())
+		}
+		if err, ok := result.(*object.Error); ok {
+			return vm.callError(err)
+		}
+		vm.sp -= argc + 1
+		vm.push(result)
+		return nil
+	}
+
+	if argc != cl.Fn.NumParams {
+		return vm.callError(object.NewError("wrong number of arguments: expected %d, got %d", cl.Fn.NumParams, argc))
+	}
+	if cl.Fn.Generator {
+		args := make([]object.Object, argc)
+		copy(args, vm.stack[vm.sp-argc:vm.sp])
+		vm.sp -= argc + 1
+		vm.push(newGenerator(cl, args))
+		return nil
+	}
+	if len(vm.frames) == maxFrames {
+		return vm.callError(object.NewError("stack overflow"))
+	}
+
+	bp := vm.sp - argc
+	vm.reserve(cl.Fn.NumLocals - argc)
+	if len(vm.stack) > maxStackSize {
+		return vm.callError(object.NewError("stack overflow"))
+	}
+	for i := 0; i < argc; i++ {
+		vm.stack[bp+i] = &cell{Value: vm.stack[bp+i]}
+	}
+	for i := argc; i < cl.Fn.NumLocals; i++ {
+		vm.stack[bp+i] = nil
+	}
+	vm.sp = bp + cl.Fn.NumLocals
+
+	vm.frames = append(vm.frames, frame{cl: cl, bp: bp})
+	return nil
+}
+
+// callError attributes an error raised by calling a function, rather than
+// inside it, to the call in the current frame.
+func (vm *vm) callError(err *object.Error) *object.Error {
+	f := &vm.frames[len(vm.frames)-1]
+	pos := f.cl.Fn.PositionAt(f.op)
+	file := f.cl.module.obj.Path
+	err.At(pos.Pos).In(file)
+	err.Stack = append(err.Stack, object.Frame{Function: pos.Callee, File: file, Pos: pos.Pos})
+	return err
+}
+
+func (vm *vm) iterNext(f *frame, target int, hasKey bool) *object.Error {
+	st := vm.stack[vm.sp-1].(*iterState)
+	for {
+		value, ok := st.iter.Next()
+		if !ok {
+			f.ip = target
+			return nil
+		}
+		if err, ok := value.(*object.Error); ok {
+			return err
+		}
+
+		var key object.Object = &object.Integer{Value: st.count}
+		st.count++
+		if st.hash != nil && hasKey {
+			key = value
+			if value, ok = st.hash.Get(key.(object.Hashable)); !ok {
+				// deleted by an earlier iteration
+				continue
+			}
+		}
+
+		if hasKey {
+			vm.push(key)
+		}
+		vm.push(value)
+		return nil
+	}
+}
+
+func (vm *vm) importModule(f *frame, path string) (*object.Module, *object.Error) {
+	from := f.cl.module.obj
+	if from.Importer == nil {
+		return nil, object.NewError("cannot import %s: imports are not available here", path)
+	}
+
+	mod, err := from.Importer.Import(path, from)
+	if err != nil {
+		if err.File != "" {
+			pos := f.cl.Fn.PositionAt(f.op)
+			err.Stack = append(err.Stack, object.Frame{Function: "import " + path, File: from.Path, Pos: pos.Pos})
+		}
+		return nil, err
+	}
+	return mod, nil
+}
+
+// throw unwinds the stack to the innermost handler that stops pending, an
+// error or the signal that closes a generator, and carries on from its
+// target. An error is attributed to the instruction being run, unless it
+// already has a position, and gets a stack frame for every call it unwinds.
+// throw returns pending if no handler stops it, keeping only the innermost
+// and outermost maxTraceFrames calls of the trace of a deep stack.
+func (vm *vm) throw(pending object.Object) object.Object {
+	err, isError := pending.(*object.Error)
+	if isError {
+		f := &vm.frames[len(vm.frames)-1]
+		err.At(f.cl.Fn.PositionAt(f.op).Pos)
+	}
+
+	for len(vm.handlers) > 0 {
+		h := vm.handlers[len(vm.handlers)-1]
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		if h.catch && !isError {
+			continue
+		}
+
+		vm.unwind(h.frame, err)
+		vm.drop(h.sp)
+		vm.frames[h.frame].ip = h.target
+		if h.catch {
+			vm.push(runtime.CaughtValue(err))
+		} else {
+			vm.push(pending)
+		}
+		return nil
+	}
+
+	vm.unwind(0, err)
+	vm.drop(0)
+	if isError {
+		return err.Elide(maxTraceFrames).In(vm.frames[0].cl.module.obj.Path)
+	}
+	return pending
+}
+
+// unwind drops the frames above the frame at index frame, adding the call
+// of each to the trace of err if there is one.
+func (vm *vm) unwind(frame int, err *object.Error) {
+	for i := len(vm.frames) - 1; err != nil && i > frame; i-- {
+		callee, caller := &vm.frames[i], &vm.frames[i-1]
+		err.In(callee.cl.module.obj.Path)
+
+		pos := caller.cl.Fn.PositionAt(caller.op)
+		err.Stack = append(err.Stack, object.Frame{Function: pos.Callee, File: caller.cl.module.obj.Path, Pos: pos.Pos})
+	}
+	vm.frames = vm.frames[:frame+1]
+}
+
+// drop discards the values above sp, closing the iterators of the for-in
+// loops among them.
+func (vm *vm) drop(sp int) {
+	for i := vm.sp - 1; vm.closers > 0 && i >= sp; i-- {
+		if st, ok := vm.stack[i].(*iterState); ok {
+			vm.close(st)
+		}
+	}
+	vm.sp = sp
+}
+
+// close closes the iterator of a for-in loop the vm is done with.
+func (vm *vm) close(st *iterState) {
+	if st.closer != nil {
+		vm.closers--
+		closer := st.closer
+		st.closer = nil
+		closer.Close()
+	}
+}
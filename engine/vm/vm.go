@@ -0,0 +1,160 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package vm runs programs compiled by package compiler on a stack
+// machine. Values and operators behave as in package runtime, whose
+// helpers the vm calls for everything but the simplest integer arithmetic.
+package vm
+
+import (
+	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/compiler"
+	"github.com/danecwalker/ponic/engine/object"
+	"github.com/danecwalker/ponic/engine/runtime"
+)
+
+const (
+	initialStackSize = 1024
+	maxStackSize     = 1 << 20
+	maxFrames        = runtime.MaxCallDepth + 1 // and one for the top level
+	maxTraceFrames   = runtime.MaxTraceFrames
+)
+
+// Engine runs modules on the vm. It implements runtime.Engine, so a
+// runtime.Loader with it as its Engine runs every module on the vm.
+type Engine struct {
+	modules map[*object.Module]*module
+}
+
+func New() *Engine {
+	return &Engine{modules: make(map[*object.Module]*module)}
+}
+
+// module holds the globals of a module run by the vm.
+type module struct {
+	obj     *object.Module
+	globals *compiler.Globals
+	values  []object.Object
+}
+
+func (m *module) lookup(name string) (object.Object, bool) {
+	slot, ok := m.globals.Slot(name)
+	if !ok || slot >= len(m.values) || m.values[slot] == nil {
+		return nil, false
+	}
+	return m.values[slot], true
+}
+
+// RunModule compiles program and runs it at the top level of mod. Running
+// several programs in the same module keeps the globals of earlier ones.
+func (e *Engine) RunModule(mod *object.Module, program *ast.AST) object.Object {
+	m, ok := e.modules[mod]
+	if !ok {
+		m = &module{obj: mod, globals: compiler.NewGlobals()}
+		mod.Lookup = m.lookup
+		e.modules[mod] = m
+	}
+
+	prog, err := compiler.Compile(program, m.globals)
+	if err != nil {
+		ce := err.(*compiler.Error)
+		return &object.Error{Message: ce.Message, Pos: ce.Pos}
+	}
+	for _, name := range prog.Exports {
+		mod.Exports[name] = true
+	}
+	for len(m.values) < len(m.globals.Names) {
+		m.values = append(m.values, nil)
+	}
+
+	vm := &vm{stack: make([]object.Object, initialStackSize)}
+	return vm.run(&Closure{Fn: prog.Main, module: m})
+}
+
+// Closure is a compiled function together with the variables it captured
+// and the module it was defined in.
+type Closure struct {
+	Fn     *compiler.Function
+	Free   []*cell
+	module *module
+}
+
+func (c *Closure) Type() object.Type {
+	return object.FUNCTION
+}
+func (c *Closure) Inspect() string {
+	return "function"
+}
+func (c *Closure) String() string {
+	return "Closure(" + c.Fn.Name + ")"
+}
+
+// cell holds the value of a local variable. Closures capture the cell
+// rather than the value, so assignments on either side are seen by both.
+// Value is nil until the declaration of a hoisted variable has run.
+type cell struct {
+	Value object.Object
+}
+
+func (c *cell) Type() object.Type {
+	return object.NULL
+}
+func (c *cell) Inspect() string {
+	return "cell"
+}
+func (c *cell) String() string {
+	return "Cell()"
+}
+
+// iterState is the state of a for-in loop, kept on the stack while the
+// loop runs.
+type iterState struct {
+	iter  object.Iterator
+	hash  *object.Hash
+	count int64
+	// closer is iter if it must be closed when the loop ends, until it is
+	closer object.Closer
+}
+
+func (s *iterState) Type() object.Type {
+	return object.ITERATOR
+}
+func (s *iterState) Inspect() string {
+	return "iterator"
+}
+func (s *iterState) String() string {
+	return "IterState()"
+}
+
+type frame struct {
+	cl *Closure
+	// ip is the offset of the next instruction and op the offset of the
+	// one being run, for error positions
+	ip int
+	op int
+	// bp is the index in the stack of local slot 0
+	bp int
+}
+
+// handler is a try block in progress. When the block is unwound the vm
+// drops the frames and values the block added and jumps to target in the
+// frame at index frame.
+type handler struct {
+	frame  int
+	sp     int
+	target int
+	// catch is set on the handler of a catch clause, which stops errors
+	// only; the handler of a finally block stops anything that unwinds
+	catch bool
+}
+
+type vm struct {
+	stack    []object.Object
+	sp       int
+	frames   []frame
+	handlers []handler
+	// closers is the number of iterators on the stack that must be closed
+	closers int
+}
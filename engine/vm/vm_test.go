@@ -0,0 +1,52 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danecwalker/ponic/engine/internal/testutil"
+	"github.com/danecwalker/ponic/engine/object"
+	"github.com/danecwalker/ponic/engine/runtime"
+)
+
+// run runs src as the top level of a new module on the vm and returns the
+// value of its last statement.
+func run(t *testing.T, src string) object.Object {
+	t.Helper()
+	program := testutil.Parse(t, src)
+
+	l := runtime.NewLoader()
+	l.Engine = New()
+	return l.Run(l.NewModule(""), program)
+}
+
+func TestLongFunction(t *testing.T) {
+	src := "fn f(a) { if (a) { " + strings.Repeat("a; ", 22000) + "} return 1; } f(true)"
+	got := run(t, src)
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("got %s, want a compile error", got.Inspect())
+	}
+	if want := "function f is too long: its code exceeds 65535 bytes"; err.Message != want {
+		t.Errorf("got error %q, want %q", err.Message, want)
+	}
+}
+
+func TestGeneratorResumingItself(t *testing.T) {
+	got := run(t, `
+fn gen() { yield next(g); }
+let g = gen();
+next(g)`)
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("got %s, want an error", got.Inspect())
+	}
+	if want := "generator is already running"; err.Message != want {
+		t.Errorf("got error %q, want %q", err.Message, want)
+	}
+}
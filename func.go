@@ -0,0 +1,108 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ponic
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+// RegisterFunc binds name in the global scope to a builtin that calls fn,
+// which must be a Go function. Arguments are converted with FromObject and
+// results with ToObject; a call with the wrong number of arguments or an
+// argument that does not convert is a Ponic runtime error.
+//
+// fn may return nothing, one value, an error, or a value and an error. A
+// non-nil error is raised as a Ponic runtime error with its message, as is
+// a panic in fn.
+func (i *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return fmt.Errorf("RegisterFunc %s: expected a function, got %T", name, fn)
+	}
+
+	builtin, err := wrapFunc(name, v)
+	if err != nil {
+		return fmt.Errorf("RegisterFunc %s: %w", name, err)
+	}
+	i.globals.Set(name, &object.Builtin{Func: builtin}, object.LET)
+	return nil
+}
+
+// wrapFunc adapts a Go function to a builtin, checking its signature once
+// up front.
+func wrapFunc(name string, fn reflect.Value) (object.BuiltinFunction, error) {
+	t := fn.Type()
+
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	switch {
+	case t.NumOut() > 2:
+		return nil, fmt.Errorf("functions may return at most two values, %s returns %d", t, t.NumOut())
+	case t.NumOut() == 2 && !returnsError:
+		return nil, fmt.Errorf("the second result of %s must be an error", t)
+	}
+
+	return func(args ...object.Object) (result object.Object) {
+		defer func() {
+			if r := recover(); r != nil {
+				result = object.NewError("panic in `%s`: %v", name, r)
+			}
+		}()
+
+		in, err := convertArgs(name, t, args)
+		if err != nil {
+			return err
+		}
+
+		out := fn.Call(in)
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return object.NewError("%s", err)
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return &object.Null{}
+		}
+
+		val, convErr := toObject(out[0])
+		if convErr != nil {
+			return object.NewError("result of `%s`: %s", name, convErr)
+		}
+		return val
+	}, nil
+}
+
+func convertArgs(name string, t reflect.Type, args []object.Object) ([]reflect.Value, *object.Error) {
+	fixed := t.NumIn()
+	if t.IsVariadic() {
+		fixed--
+		if len(args) < fixed {
+			return nil, object.NewError("wrong number of arguments: expected at least %d, got %d", fixed, len(args))
+		}
+	} else if len(args) != fixed {
+		return nil, object.NewError("wrong number of arguments: expected %d, got %d", fixed, len(args))
+	}
+
+	in := make([]reflect.Value, len(args))
+	for n, arg := range args {
+		var paramType reflect.Type
+		if n < fixed {
+			paramType = t.In(n)
+		} else {
+			paramType = t.In(fixed).Elem()
+		}
+
+		v, err := fromObject(arg, paramType)
+		if err != nil {
+			return nil, object.NewError("argument %d to `%s`: %s", n+1, name, err)
+		}
+		in[n] = v
+	}
+	return in, nil
+}
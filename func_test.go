@@ -0,0 +1,124 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ponic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRegisterFunc(t *testing.T) {
+	interp := New()
+	funcs := map[string]interface{}{
+		"add":   func(a, b int) int { return a + b },
+		"half":  func(f float64) float64 { return f / 2 },
+		"upper": strings.ToUpper,
+		"sum": func(ns ...int) int {
+			s := 0
+			for _, n := range ns {
+				s += n
+			}
+			return s
+		},
+		"join":  func(sep string, parts []string) string { return strings.Join(parts, sep) },
+		"names": func(m map[string]int) []string { return []string{fmt.Sprint(len(m))} },
+		"noop":  func() {},
+		"divide": func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, errors.New("divide by zero")
+			}
+			return a / b, nil
+		},
+		"fail":   func() error { return errors.New("failed") },
+		"boom":   func() int { panic("kaboom") },
+		"origin": func() point { return point{Label: "o"} },
+	}
+	for name, fn := range funcs {
+		if err := interp.RegisterFunc(name, fn); err != nil {
+			t.Fatalf("RegisterFunc %s: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`add(2, 3)`, "5"},
+		{`half(3)`, "1.5"},
+		{`upper("abc")`, "ABC"},
+		{`sum()`, "0"},
+		{`sum(1, 2, 3)`, "6"},
+		{`join("-", ["a", "b"])`, "a-b"},
+		{`names({"a": 1, "b": 2})`, `["2"]`},
+		{`noop()`, "null"},
+		{`divide(7, 2)`, "3"},
+		{`origin().label`, "o"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := interp.Eval(tt.src)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got.Inspect() != tt.want {
+				t.Errorf("got %s, want %s", got.Inspect(), tt.want)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		src  string
+		want string
+	}{
+		{`add(1)`, "1:1: wrong number of arguments: expected 2, got 1"},
+		{`add(1, 2, 3)`, "1:1: wrong number of arguments: expected 2, got 3"},
+		{`sum(1, "two")`, "1:1: argument 2 to `sum`: cannot use two as int"},
+		{`add(1, 2.5)`, "1:1: argument 2 to `add`: cannot use 2.5 as int"},
+		{`upper(1)`, "1:1: argument 1 to `upper`: cannot use 1 as string"},
+		{`join(",", [1])`, "1:1: argument 2 to `join`: index 0: cannot use 1 as string"},
+		{`divide(1, 0)`, "1:1: divide by zero"},
+		{`fail()`, "1:1: failed"},
+		{`boom()`, "1:1: panic in `boom`: kaboom"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := interp.Eval(tt.src)
+			if err == nil {
+				t.Fatalf("got %s, want error %q", got.Inspect(), tt.want)
+			}
+			if msg := strings.SplitN(err.Error(), "\n", 2)[0]; msg != tt.want {
+				t.Errorf("got error %q, want %q", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterFuncSignatures(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   interface{}
+		want string
+	}{
+		{"not a function", 42, "RegisterFunc f: expected a function, got int"},
+		{"nil function", (func())(nil), "RegisterFunc f: expected a function, got func()"},
+		{"three results", func() (int, int, error) { return 0, 0, nil }, "RegisterFunc f: functions may return at most two values, func() (int, int, error) returns 3"},
+		{"second result not an error", func() (int, int) { return 0, 0 }, "RegisterFunc f: the second result of func() (int, int) must be an error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New().RegisterFunc("f", tt.fn)
+			if err == nil {
+				t.Fatalf("got no error, want %q", tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got error %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -2,8 +2,9 @@ module github.com/danecwalker/ponic
 
 go 1.19
 
+require github.com/spf13/cobra v1.5.0
+
 require (
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
-	github.com/spf13/cobra v1.5.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 )
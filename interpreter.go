@@ -0,0 +1,177 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package ponic embeds the Ponic interpreter in Go programs.
+//
+//	interp := ponic.New()
+//	interp.SetGlobal("name", "world")
+//	if _, err := interp.Eval(`print("hello " + name)`); err != nil {
+//		log.Fatal(err)
+//	}
+package ponic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/danecwalker/ponic/engine/ast"
+	"github.com/danecwalker/ponic/engine/lexer"
+	"github.com/danecwalker/ponic/engine/object"
+	"github.com/danecwalker/ponic/engine/parser"
+	"github.com/danecwalker/ponic/engine/runtime"
+	"github.com/danecwalker/ponic/engine/vm"
+)
+
+// Engine selects how an Interpreter runs programs.
+type Engine int
+
+const (
+	// Walker runs programs by walking their syntax tree.
+	Walker Engine = iota
+	// VM compiles programs to bytecode and runs them on a stack machine.
+	VM
+)
+
+// Interpreter runs Ponic source. Bindings made by one call to Eval are
+// visible to the next. An Interpreter must not be used from more than one
+// goroutine at a time.
+type Interpreter struct {
+	io      *runtime.IO
+	loader  *runtime.Loader
+	globals *object.Scope
+	main    *object.Module
+}
+
+// New returns an interpreter attached to the standard streams of the
+// process.
+func New() *Interpreter {
+	i := &Interpreter{
+		io:      &runtime.IO{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr},
+		loader:  runtime.NewLoader(),
+		globals: object.NewScope(),
+	}
+	for name, fn := range i.io.Builtins() {
+		i.globals.Set(name, &object.Builtin{Func: fn}, object.CONST)
+	}
+	i.loader.Prelude = i.globals
+	i.main = i.loader.NewModule("")
+	return i
+}
+
+// SetEngine sets the engine that runs the scripts and modules the
+// interpreter loads from then on. Modules already loaded stay with the
+// engine that ran them, so it should be called before anything is run.
+func (i *Interpreter) SetEngine(e Engine) {
+	switch e {
+	case VM:
+		i.loader.Engine = vm.New()
+	default:
+		i.loader.Engine = nil
+	}
+}
+
+// SetStdin sets where the scan builtin reads from.
+func (i *Interpreter) SetStdin(r io.Reader) {
+	i.io.Stdin = r
+}
+
+// SetStdout sets where the print builtin writes to.
+func (i *Interpreter) SetStdout(w io.Writer) {
+	i.io.Stdout = w
+}
+
+// SetStderr sets where the eprint builtin writes to.
+func (i *Interpreter) SetStderr(w io.Writer) {
+	i.io.Stderr = w
+}
+
+// SetGlobal binds name in the global scope shared by every module the
+// interpreter runs to value, converted with ToObject. A binding of the same
+// name made by a script takes precedence over it.
+func (i *Interpreter) SetGlobal(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return fmt.Errorf("SetGlobal %s: %w", name, err)
+	}
+	i.globals.Set(name, obj, object.LET)
+	return nil
+}
+
+// GetGlobal returns the value of name at the top level of the last script
+// run, falling back to the values set with SetGlobal.
+func (i *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	return i.main.Get(name)
+}
+
+// Eval runs src at the top level of the interpreter and returns the value
+// of its last statement. A top-level return ends the script; its value is
+// returned wrapped in an *object.ReturnValue.
+//
+// A syntax error is returned as a *SyntaxError and a runtime error as an
+// *object.Error.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	program, err := parse("", strings.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	return result(i.loader.Run(i.main, program))
+}
+
+// RunFile runs the file at path as a module, which becomes the top level
+// of the interpreter for GetGlobal and later calls to Eval. Its imports
+// resolve relative to its directory.
+func (i *Interpreter) RunFile(path string) (object.Object, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	program, err := parse(path, f)
+	if err != nil {
+		return nil, err
+	}
+
+	mod, val := i.loader.Exec(path, program)
+	i.main = mod
+	return result(val)
+}
+
+func parse(file string, r io.Reader) (*ast.AST, error) {
+	program, diagnostics := parser.NewParser(lexer.NewLexer(bufio.NewReader(r))).Parse()
+	if len(diagnostics) > 0 {
+		return nil, &SyntaxError{File: file, Diagnostics: diagnostics}
+	}
+	return program, nil
+}
+
+func result(val object.Object) (object.Object, error) {
+	if err, ok := val.(*object.Error); ok {
+		return nil, err
+	}
+	return val, nil
+}
+
+// SyntaxError is returned for source that does not parse. It holds every
+// diagnostic the parser reported.
+type SyntaxError struct {
+	File        string
+	Diagnostics []parser.Diagnostic
+}
+
+func (e *SyntaxError) Error() string {
+	lines := make([]string, len(e.Diagnostics))
+	for n, d := range e.Diagnostics {
+		if e.File != "" {
+			lines[n] = e.File + ":" + d.String()
+		} else {
+			lines[n] = d.String()
+		}
+	}
+	return strings.Join(lines, "\n")
+}
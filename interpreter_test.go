@@ -0,0 +1,182 @@
+// Copyright (c) 2022 DevDane <dane@danecwalker.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ponic
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danecwalker/ponic/engine/object"
+)
+
+var engines = []struct {
+	name   string
+	engine Engine
+}{
+	{"walker", Walker},
+	{"vm", VM},
+}
+
+// newInterpreter returns an interpreter on engine whose output is collected
+// in out and errOut.
+func newInterpreter(engine Engine, out, errOut *strings.Builder) *Interpreter {
+	interp := New()
+	interp.SetEngine(engine)
+	interp.SetStdout(out)
+	interp.SetStderr(errOut)
+	return interp
+}
+
+func TestEval(t *testing.T) {
+	for _, e := range engines {
+		t.Run(e.name, func(t *testing.T) {
+			var out, errOut strings.Builder
+			interp := newInterpreter(e.engine, &out, &errOut)
+
+			got, err := interp.Eval(`let n = 20; n + 1`)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got.Inspect() != "21" {
+				t.Errorf("got %s, want 21", got.Inspect())
+			}
+
+			// bindings carry over to the next call
+			got, err = interp.Eval(`return n * 2;`)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			ret, ok := got.(*object.ReturnValue)
+			if !ok || ret.Value.Inspect() != "40" {
+				t.Errorf("got %s, want a return of 40", got.Inspect())
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, e := range engines {
+		t.Run(e.name, func(t *testing.T) {
+			var out, errOut strings.Builder
+			interp := newInterpreter(e.engine, &out, &errOut)
+
+			_, err := interp.Eval("fn f() { return [][0]; }\nf()")
+			rtErr, ok := err.(*object.Error)
+			if !ok {
+				t.Fatalf("got %v, want an *object.Error", err)
+			}
+			if want := "1:19: array index 0 out of range with length 0\n    at f (2:1)"; rtErr.Error() != want {
+				t.Errorf("got error\n%s\nwant\n%s", rtErr, want)
+			}
+
+			_, err = interp.Eval(`let = 1;`)
+			synErr, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("got %v, want a *SyntaxError", err)
+			}
+			if want := "1:5: expected `IDENT`, found `=`"; synErr.Error() != want {
+				t.Errorf("got error %q, want %q", synErr, want)
+			}
+
+			if out.String() != "" || errOut.String() != "" {
+				t.Errorf("errors were printed: stdout %q, stderr %q", out.String(), errOut.String())
+			}
+		})
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	for _, e := range engines {
+		t.Run(e.name, func(t *testing.T) {
+			var out, errOut strings.Builder
+			interp := newInterpreter(e.engine, &out, &errOut)
+
+			if err := interp.SetGlobal("limits", map[string]int{"max": 3}); err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := interp.GetGlobal("limits"); !ok || got.Inspect() != `{"max": 3}` {
+				t.Errorf("got limits %v, want the hash that was set", got)
+			}
+
+			if _, err := interp.Eval(`let doubled = limits["max"] * 2;`); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, ok := interp.GetGlobal("doubled"); !ok || got.Inspect() != "6" {
+				t.Errorf("got doubled %v, want 6", got)
+			}
+
+			if _, ok := interp.GetGlobal("missing"); ok {
+				t.Errorf("got a value for a global that was never bound")
+			}
+		})
+	}
+}
+
+func TestOutput(t *testing.T) {
+	for _, e := range engines {
+		t.Run(e.name, func(t *testing.T) {
+			var out, errOut strings.Builder
+			interp := newInterpreter(e.engine, &out, &errOut)
+
+			if _, err := interp.Eval(`print("a", 1); eprint("oops"); print("b")`); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if out.String() != "a1b" {
+				t.Errorf("got stdout %q, want %q", out.String(), "a1b")
+			}
+			if errOut.String() != "oops" {
+				t.Errorf("got stderr %q, want %q", errOut.String(), "oops")
+			}
+		})
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.pc":      "import \"./lib/greet.pc\"\nlet message = greet.hello(\"file\");\nmessage",
+		"lib/greet.pc": `export fn hello(name) { return "hello " + name; }`,
+		"broken.pc":    "let x = 1;\nx()",
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, e := range engines {
+		t.Run(e.name, func(t *testing.T) {
+			var out, errOut strings.Builder
+			interp := newInterpreter(e.engine, &out, &errOut)
+
+			// the import resolves next to main.pc, not the working directory
+			got, err := interp.RunFile(filepath.Join(dir, "main.pc"))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got.Inspect() != "hello file" {
+				t.Errorf("got %s, want hello file", got.Inspect())
+			}
+			if got, ok := interp.GetGlobal("message"); !ok || got.Inspect() != "hello file" {
+				t.Errorf("got message %v, want the global of main.pc", got)
+			}
+
+			_, err = interp.RunFile(filepath.Join(dir, "broken.pc"))
+			if err == nil {
+				t.Fatal("got no error running broken.pc")
+			}
+			if want := filepath.Join(dir, "broken.pc") + ":2:1: 1 is not a function"; err.Error() != want {
+				t.Errorf("got error %q, want %q", err, want)
+			}
+		})
+	}
+}